@@ -0,0 +1,50 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps opaque blobs under string keys. Callers encrypt anything
+// sensitive before handing it over; stores never see plaintext.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Default is the store used by the rest of the bot, set up by Init.
+var Default Store
+
+// Init configures Default from the environment. BLOB_STORE selects the
+// backend: "local" (the default) writes under BLOB_DIR, "s3" talks to an
+// S3-compatible endpoint configured by S3_ENDPOINT, S3_REGION, S3_BUCKET,
+// S3_ACCESS_KEY and S3_SECRET_KEY.
+func Init() {
+	switch os.Getenv("BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "blobs"
+		}
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			log.Fatalf("failed to set up local blob store: %v", err)
+		}
+		Default = store
+	case "s3":
+		client := &S3Client{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		Default = NewS3Store(client, os.Getenv("S3_BUCKET"))
+	default:
+		log.Fatalf("unknown BLOB_STORE %q", os.Getenv("BLOB_STORE"))
+	}
+}
@@ -0,0 +1,191 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ObjectClient is the subset of an S3-compatible API the S3 store needs.
+// S3Client implements it over HTTP; anything else that speaks the same
+// operations, such as an in-process stand-in, can be used instead.
+type ObjectClient interface {
+	PutObject(ctx context.Context, bucket, key string, data []byte) error
+	GetObject(ctx context.Context, bucket, key string) ([]byte, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// S3Store keeps blobs as objects in a single bucket.
+type S3Store struct {
+	client ObjectClient
+	bucket string
+}
+
+func NewS3Store(client ObjectClient, bucket string) *S3Store {
+	return &S3Store{client: client, bucket: bucket}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	return s.client.PutObject(ctx, s.bucket, key, data)
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.client.GetObject(ctx, s.bucket, key)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.client.DeleteObject(ctx, s.bucket, key)
+}
+
+// S3Client is a minimal S3 client using path-style URLs and Signature
+// Version 4, enough for AWS S3 and MinIO-compatible servers.
+type S3Client struct {
+	Endpoint   string
+	Region     string
+	AccessKey  string
+	SecretKey  string
+	HTTPClient *http.Client
+}
+
+func (c *S3Client) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	res, err := c.do(ctx, http.MethodPut, bucket, key, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkS3Response(res)
+}
+
+func (c *S3Client) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	res, err := c.do(ctx, http.MethodGet, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err := checkS3Response(res); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(res.Body)
+}
+
+func (c *S3Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	res, err := c.do(ctx, http.MethodDelete, bucket, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkS3Response(res)
+}
+
+func checkS3Response(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("s3 request failed (status %d): %s", res.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (c *S3Client) do(ctx context.Context, method, bucket, key string, body []byte) (*http.Response, error) {
+	if c.Endpoint == "" || bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket must be set")
+	}
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	endpoint.Path = "/" + bucket + "/" + key
+	endpoint.RawPath = "/" + s3Escape(bucket) + "/" + s3Escape(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, body, time.Now().UTC())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (c *S3Client) sign(req *http.Request, body []byte, now time.Time) {
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3Escape percent-encodes everything but unreserved characters and slashes,
+// as S3 expects in canonical URIs.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,130 @@
+// Command admin answers data access and deletion requests for a sender from
+// outside Messenger. It reads the same .env as the bot:
+//
+//	admin summary <senderID>
+//	admin delete [-yes] <senderID>
+//	admin link open <storageID> | join <code> | new [name]
+//
+// A running bot notices the deletion on the sender's next message. Links are
+// m.me links to the page in PAGE_USERNAME that start the given action.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/markDoesany/quickymessenger/blobstore"
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/deeplink"
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "summary":
+		flags := flag.NewFlagSet("summary", flag.ExitOnError)
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 {
+			usage()
+		}
+		database.InitDB()
+		summary, err := database.GetDataSummary(flags.Arg(0))
+		if err != nil {
+			log.Fatalf("Failed to summarize data: %v", err)
+		}
+		printSummary(summary)
+	case "delete":
+		flags := flag.NewFlagSet("delete", flag.ExitOnError)
+		yes := flags.Bool("yes", false, "delete without asking for confirmation")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 {
+			usage()
+		}
+		senderID := flags.Arg(0)
+		database.InitDB()
+		blobstore.Init()
+
+		summary, err := database.GetDataSummary(senderID)
+		if err != nil {
+			log.Fatalf("Failed to summarize data: %v", err)
+		}
+		if summary.Empty() {
+			fmt.Println("Nothing is stored for", senderID)
+			return
+		}
+		printSummary(summary)
+		if !*yes && !confirm("Delete all of this?") {
+			fmt.Println("Cancelled.")
+			return
+		}
+		summary, err = database.DeleteUserData(senderID, models.DeletionByAdmin)
+		if err != nil {
+			log.Fatalf("Failed to delete data: %v", err)
+		}
+		fmt.Printf("Deleted %d storages, %d entries and %d files. Tombstone: %s\n", len(summary.Storages), summary.Entries, summary.Files, database.SenderHash(senderID))
+	case "link":
+		if len(os.Args) < 3 || len(os.Args) > 4 {
+			usage()
+		}
+		link := deeplink.Link{Action: os.Args[2]}
+		if len(os.Args) == 4 {
+			link.Arg = os.Args[3]
+		}
+		if link.Action != deeplink.CreateStorage && link.Arg == "" {
+			usage()
+		}
+		page := os.Getenv("PAGE_USERNAME")
+		if page == "" {
+			log.Fatal("PAGE_USERNAME is not set")
+		}
+		ref, err := deeplink.Ref([]byte(os.Getenv("ENCRYPTION_KEY")), link)
+		if err != nil {
+			usage()
+		}
+		fmt.Println(deeplink.URL(page, ref))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin summary <senderID>")
+	fmt.Fprintln(os.Stderr, "       admin delete [-yes] <senderID>")
+	fmt.Fprintln(os.Stderr, "       admin link open <storageID> | join <code> | new [name]")
+	os.Exit(2)
+}
+
+func printSummary(summary database.DataSummary) {
+	fmt.Printf("Storages:  %d\n", len(summary.Storages))
+	for _, storage := range summary.Storages {
+		fmt.Printf("  %s: %d entries\n", storage.Name, storage.Entries)
+	}
+	fmt.Printf("Entries:   %d\n", summary.Entries)
+	fmt.Printf("Files:     %d (%d bytes)\n", summary.Files, summary.FileBytes)
+	fmt.Printf("Revisions: %d\n", summary.Revisions)
+	fmt.Printf("Tags:      %d\n", summary.Tags)
+	fmt.Printf("Reminders: %d\n", summary.Reminders)
+	fmt.Printf("Renames:   %d\n", summary.Renames)
+	fmt.Printf("Shares:    %d\n", summary.Shares)
+	fmt.Printf("Settings:  %t\n", summary.Preferences)
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/blobstore"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "attachments/" + hex.EncodeToString(b), nil
+}
+
+// StoreAttachmentInDB encrypts data into the blob store and records it as a
+// new media entry of the storage.
+func StoreAttachmentInDB(senderID, storageName string, timestamp time.Time, attachment models.Attachment, data []byte) error {
+	payload, err := models.EncodePayload(attachment.Payload())
+	if err != nil {
+		return err
+	}
+
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	encryptedData, err := utils.EncryptBytes(data, encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	blobKey, err := newBlobKey()
+	if err != nil {
+		return err
+	}
+	if err := blobstore.Default.Put(context.Background(), blobKey, encryptedData); err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		content, err := storeData(tx, senderID, storageName, timestamp, models.ContentTypeMedia, payload)
+		if err != nil {
+			return err
+		}
+		attachment.ContentID = content.ID
+		attachment.BlobKey = blobKey
+		attachment.Size = int64(len(data))
+		return tx.Create(&attachment).Error
+	})
+	if err != nil {
+		deleteBlobs([]string{blobKey})
+		return err
+	}
+	return nil
+}
+
+// GetAttachmentData returns the decrypted file of an attachment.
+func GetAttachmentData(attachment models.Attachment) ([]byte, error) {
+	encryptedData, err := blobstore.Default.Get(context.Background(), attachment.BlobKey)
+	if err != nil {
+		return nil, err
+	}
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	return utils.DecryptBytes(encryptedData, encryptionKey)
+}
+
+// deleteBlobs removes blobs whose rows are already gone. Failures only leave
+// unreadable encrypted files behind, so they are logged rather than returned.
+func deleteBlobs(keys []string) {
+	for _, key := range keys {
+		if err := blobstore.Default.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
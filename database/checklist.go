@@ -0,0 +1,64 @@
+package database
+
+import (
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// SetStorageMode switches one of the sender's storages between notes and
+// checklist mode. Entries keep their ticks when switching back and forth.
+func SetStorageMode(senderID string, storageID uint, mode string) error {
+	storageContent, err := ownedStorage(DB, senderID, storageID)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&storageContent).Update("mode", mode).Error
+}
+
+// ToggleContentDone ticks a checklist item the sender can change off, or back
+// on, and returns its new state.
+func ToggleContentDone(senderID string, contentID uint) (bool, error) {
+	var done bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx, senderID, contentID, true)
+		if err != nil {
+			return err
+		}
+		done = !content.Done
+		return tx.Model(&content).Update("done", done).Error
+	})
+	return done, err
+}
+
+// ClearCompleted permanently removes the ticked-off items of a storage the
+// sender can change and returns how many there were.
+func ClearCompleted(senderID string, storageID uint) (int, error) {
+	var contentIDs []uint
+	var blobKeys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := accessibleStorage(tx, senderID, storageID, true); err != nil {
+			return err
+		}
+		err := tx.Model(&models.Content{}).Where("storage_content_id = ? AND done = ?", storageID, true).Pluck("id", &contentIDs).Error
+		if err != nil {
+			return err
+		}
+		blobKeys, err = deleteContentRows(tx, contentIDs)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	deleteBlobs(blobKeys)
+	return len(contentIDs), nil
+}
+
+// UncheckAll clears the ticks of every item of a storage the sender can
+// change.
+func UncheckAll(senderID string, storageID uint) (int64, error) {
+	if _, err := accessibleStorage(DB, senderID, storageID, true); err != nil {
+		return 0, err
+	}
+	result := DB.Model(&models.Content{}).Where("storage_content_id = ? AND done = ?", storageID, true).Update("done", false)
+	return result.RowsAffected, result.Error
+}
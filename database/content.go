@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"os"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+// ErrMediaEntry is returned when replacing the data of a media entry, whose
+// attachment would no longer match it.
+var ErrMediaEntry = errors.New("media entries can't be edited")
+
+// GetContent returns a single decrypted entry the sender can see.
+func GetContent(senderID string, contentID uint) (models.Content, error) {
+	content, err := accessibleContent(DB.Preload("Attachment").Preload("Tags"), senderID, contentID, false)
+	if err != nil {
+		return models.Content{}, err
+	}
+
+	contents := []models.Content{content}
+	if err := decryptContents(contents); err != nil {
+		return models.Content{}, err
+	}
+	return contents[0], nil
+}
+
+// UpdateContent replaces the data of an entry the sender can change, encrypting
+// it afresh. The previous data is kept as a revision. Media entries can't be
+// changed and give ErrMediaEntry.
+func UpdateContent(senderID string, contentID uint, contentType, data string) error {
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	encryptedData, err := utils.Encrypt(data, encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx, senderID, contentID, true)
+		if err != nil {
+			return err
+		}
+		if content.Type == models.ContentTypeMedia {
+			return ErrMediaEntry
+		}
+		return replaceContentData(tx, content, contentType, encryptedData)
+	})
+}
+
+// replaceContentData stores the entry's current ciphertext as a revision and
+// sets encryptedData of contentType in its place.
+func replaceContentData(tx *gorm.DB, content models.Content, contentType, encryptedData string) error {
+	revision := models.ContentRevision{
+		ContentID: content.ID,
+		Timestamp: content.UpdatedAt,
+		Type:      content.Type,
+		Data:      content.Data,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return err
+	}
+	return tx.Model(&content).Updates(map[string]interface{}{"type": contentType, "data": encryptedData}).Error
+}
+
+// DeleteContent permanently removes an entry the sender can change.
+func DeleteContent(senderID string, contentID uint) error {
+	var blobKeys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx, senderID, contentID, true)
+		if err != nil {
+			return err
+		}
+		blobKeys, err = deleteContentRows(tx, []uint{content.ID})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	deleteBlobs(blobKeys)
+	return nil
+}
+
+// deleteContentRows hard-deletes the given entries with their revisions,
+// reminders and attachment records. It returns the blob keys of the attachments, which the
+// caller deletes once the transaction has committed.
+func deleteContentRows(tx *gorm.DB, contentIDs []uint) ([]string, error) {
+	if len(contentIDs) == 0 {
+		return nil, nil
+	}
+
+	var blobKeys []string
+	err := tx.Model(&models.Attachment{}).Where("content_id IN (?)", contentIDs).Pluck("blob_key", &blobKeys).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("content_id IN (?)", contentIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("content_id IN (?)", contentIDs).Delete(&models.ContentRevision{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("content_id IN (?)", contentIDs).Delete(&models.Reminder{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM content_tags WHERE content_id IN ?", contentIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("id IN (?)", contentIDs).Delete(&models.Content{}).Error; err != nil {
+		return nil, err
+	}
+	return blobKeys, nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+// CreateDeletionRequest records a pending deletion of the sender's data under
+// a new random confirmation code.
+func CreateDeletionRequest(senderID string) (models.DeletionRequest, error) {
+	code := make([]byte, 8)
+	if _, err := rand.Read(code); err != nil {
+		return models.DeletionRequest{}, err
+	}
+	request := models.DeletionRequest{Code: hex.EncodeToString(code), SenderID: senderID, Status: models.DeletionRequestPending}
+	err := DB.Create(&request).Error
+	return request, err
+}
+
+// GetDeletionRequest looks up a deletion request by its confirmation code.
+func GetDeletionRequest(code string) (models.DeletionRequest, error) {
+	var request models.DeletionRequest
+	err := DB.Where("code = ?", code).First(&request).Error
+	return request, err
+}
+
+// UnfinishedDeletionRequests returns the deletion requests that haven't run
+// or failed, oldest first.
+func UnfinishedDeletionRequests() ([]models.DeletionRequest, error) {
+	var requests []models.DeletionRequest
+	err := DB.Where("status IN ?", []string{models.DeletionRequestPending, models.DeletionRequestFailed}).Order("id").Find(&requests).Error
+	return requests, err
+}
+
+// FinishDeletionRequest records how a deletion request ended. Once the data
+// is deleted, the request forgets whose it was; a failed one keeps the sender
+// ID so the deletion can be run again.
+func FinishDeletionRequest(requestID uint, status string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "completed_at": &now}
+	if status == models.DeletionRequestDone {
+		updates["sender_id"] = ""
+	}
+	return DB.Model(&models.DeletionRequest{}).Where("id = ?", requestID).Updates(updates).Error
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMove = errors.New("a storage can't be moved into itself or one of its own folders")
+
+// storageTree returns the IDs of a storage and everything nested below it,
+// parents before children.
+func storageTree(tx *gorm.DB, senderID string, storageID uint) ([]uint, error) {
+	var storageContents []models.StorageContent
+	if err := tx.Where("sender_id = ?", senderID).Find(&storageContents).Error; err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]uint{}
+	found := false
+	for _, storageContent := range storageContents {
+		if storageContent.ID == storageID {
+			found = true
+		}
+		if storageContent.ParentID != nil {
+			children[*storageContent.ParentID] = append(children[*storageContent.ParentID], storageContent.ID)
+		}
+	}
+	if !found {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	tree := []uint{storageID}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree, nil
+}
+
+// MoveStorage puts one of the sender's storages into another one, which
+// becomes a folder. A parentID of 0 moves it back to the top level.
+func MoveStorage(senderID string, storageID, parentID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		tree, err := storageTree(tx, senderID, storageID)
+		if err != nil {
+			return err
+		}
+
+		var parent *uint
+		if parentID != 0 {
+			for _, id := range tree {
+				if id == parentID {
+					return ErrInvalidMove
+				}
+			}
+			folder, err := ownedStorage(tx, senderID, parentID)
+			if err != nil {
+				return err
+			}
+			parent = &folder.ID
+		}
+
+		return tx.Model(&models.StorageContent{}).Where("id = ?", storageID).Update("parent_id", parent).Error
+	})
+}
+
+// CountStorageTree reports how many storages and entries deleting a storage
+// would remove, counting everything nested below it.
+func CountStorageTree(senderID string, storageID uint) (storages int, entries int64, err error) {
+	tree, err := storageTree(DB, senderID, storageID)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = DB.Model(&models.Content{}).Where("storage_content_id IN ?", tree).Count(&entries).Error
+	return len(tree), entries, err
+}
+
+// DeleteStorage permanently removes a storage, everything nested below it
+// and all their entries, including attached files. It returns the IDs of the
+// deleted storages.
+func DeleteStorage(senderID string, storageID uint) ([]uint, error) {
+	var tree []uint
+	var blobKeys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		tree, err = storageTree(tx, senderID, storageID)
+		if err != nil {
+			return err
+		}
+
+		var contentIDs []uint
+		err = tx.Model(&models.Content{}).Where("storage_content_id IN ?", tree).Pluck("id", &contentIDs).Error
+		if err != nil {
+			return err
+		}
+		blobKeys, err = deleteContentRows(tx, contentIDs)
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("storage_content_id IN ?", tree).Delete(&models.StorageRename{}).Error; err != nil {
+			return err
+		}
+		if err := deleteReminders(tx, tree); err != nil {
+			return err
+		}
+		if err := deleteShares(tx, tree); err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", tree).Delete(&models.StorageContent{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleteBlobs(blobKeys)
+	return tree, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// ImportEntry is one entry to import; Data is plain text or an encoded
+// payload as described in models.
+type ImportEntry struct {
+	Timestamp time.Time
+	Type      string
+	Data      string
+	Done      bool
+	Tags      []string
+}
+
+// ImportStorage holds the entries to import into a storage, which is created
+// with Mode when the sender has none by that name.
+type ImportStorage struct {
+	Name    string
+	Mode    string
+	Entries []ImportEntry
+}
+
+// ImportEntries stores every entry in one transaction, so a failed import
+// leaves nothing behind. It returns the storages it created.
+func ImportEntries(senderID string, storages []ImportStorage) ([]models.StorageContent, error) {
+	var created []models.StorageContent
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		created = nil
+		for _, storage := range storages {
+			_, err := writableStorageByName(tx, senderID, storage.Name)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err != nil {
+				storageContent := models.StorageContent{SenderID: senderID, StorageName: storage.Name, Mode: storage.Mode}
+				if storageContent.Mode == "" {
+					storageContent.Mode = models.StorageModeNotes
+				}
+				if err := tx.Create(&storageContent).Error; err != nil {
+					return err
+				}
+				created = append(created, storageContent)
+			}
+
+			for _, entry := range storage.Entries {
+				content, err := storeData(tx, senderID, storage.Name, entry.Timestamp, entry.Type, entry.Data)
+				if err != nil {
+					return err
+				}
+				if entry.Done {
+					if err := tx.Model(&content).Update("done", true).Error; err != nil {
+						return err
+					}
+				}
+				if err := tagContent(tx, senderID, content, entry.Tags); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"github.com/markDoesany/quickymessenger/utils"
+)
+
+// SetStoragePIN locks one of the sender's storages with the PIN hash was made
+// from; an empty hash unlocks it.
+func SetStoragePIN(senderID string, storageID uint, hash string) error {
+	storageContent, err := ownedStorage(DB, senderID, storageID)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&storageContent).Update("pin_hash", hash).Error
+}
+
+// CheckStoragePIN reports whether pin unlocks a storage the sender can see.
+func CheckStoragePIN(senderID string, storageID uint, pin string) (bool, error) {
+	storageContent, err := accessibleStorage(DB, senderID, storageID, false)
+	if err != nil {
+		return false, err
+	}
+	return utils.PINMatches(storageContent.PINHash, pin), nil
+}
@@ -1,9 +1,11 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/markDoesany/quickymessenger/models"
@@ -14,6 +16,24 @@ import (
 
 var DB *gorm.DB
 
+var ErrStorageExists = errors.New("storage already exists")
+
+// ContentCursor marks a position in a storage's entries ordered by timestamp.
+// The entry ID breaks ties between entries stored at the same time.
+type ContentCursor struct {
+	Timestamp time.Time
+	ID        uint
+}
+
+func (c ContentCursor) IsZero() bool {
+	return c.ID == 0
+}
+
+type SearchResult struct {
+	StorageName string
+	Content     models.Content
+}
+
 func InitDB() {
 	dsn := os.Getenv("DSN")
 	var err error
@@ -22,67 +42,351 @@ func InitDB() {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 
-	err = DB.AutoMigrate(&models.StorageContent{}, &models.Content{})
+	err = DB.AutoMigrate(&models.StorageContent{}, &models.Content{}, &models.ContentRevision{}, &models.Attachment{}, &models.Tag{}, &models.UserPreference{}, &models.StorageRename{}, &models.Reminder{}, &models.DeletionRecord{}, &models.DeletionRequest{}, &models.StorageShare{}, &models.ShareInvite{}, &models.SchemaMigration{})
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
+	if err := runMigrations(); err != nil {
+		log.Fatalf("failed to migrate data: %v", err)
+	}
 
 	fmt.Println("Database connected and migrated successfully!")
 }
 
 func StoreDataInDB(senderID, storageName string, timestamp time.Time, data string) error {
-	var storageContent models.StorageContent
-	err := DB.Where("sender_id = ? AND storage_name = ?", senderID, storageName).First(&storageContent).Error
+	return StoreTypedDataInDB(senderID, storageName, timestamp, models.ContentTypeText, data)
+}
+
+// StoreTypedDataInDB stores an entry of the given content type; data is plain
+// text or an encoded payload as described in models. The entry is tagged with
+// tags, if any.
+func StoreTypedDataInDB(senderID, storageName string, timestamp time.Time, contentType, data string, tags ...string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		content, err := storeData(tx, senderID, storageName, timestamp, contentType, data)
+		if err != nil {
+			return err
+		}
+		return tagContent(tx, senderID, content, tags)
+	})
+}
+
+// storeData adds an entry to the storage the sender calls storageName,
+// creating it for them when there is none.
+func storeData(tx *gorm.DB, senderID, storageName string, timestamp time.Time, contentType, data string) (models.Content, error) {
+	storageContent, err := writableStorageByName(tx, senderID, storageName)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			storageContent = models.StorageContent{
 				SenderID:    senderID,
 				StorageName: storageName,
 			}
-			if err := DB.Create(&storageContent).Error; err != nil {
-				return err
+			if err := tx.Create(&storageContent).Error; err != nil {
+				return models.Content{}, err
 			}
 		} else {
-			return err
+			return models.Content{}, err
 		}
 	}
 
 	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
 	encryptedData, err := utils.Encrypt(data, encryptionKey)
 	if err != nil {
-		return err
+		return models.Content{}, err
 	}
 
 	content := models.Content{
 		StorageContentID: storageContent.ID,
 		Timestamp:        timestamp,
+		Type:             contentType,
 		Data:             encryptedData,
 	}
-	return DB.Create(&content).Error
+	if err := tx.Create(&content).Error; err != nil {
+		return models.Content{}, err
+	}
+	return content, nil
 }
 
 func GetStorageData(senderID, storageName string) ([]models.Content, error) {
-	var storageContent models.StorageContent
-	err := DB.Where("sender_id = ? AND storage_name = ?", senderID, storageName).First(&storageContent).Error
+	storageContent, err := storageByName(DB, senderID, storageName)
 	if err != nil {
 		return nil, err
 	}
 
 	var contents []models.Content
-	err = DB.Where("storage_content_id = ?", storageContent.ID).Find(&contents).Error
+	err = DB.Where("storage_content_id = ?", storageContent.ID).Preload("Attachment").Preload("Tags").Order("timestamp, id").Find(&contents).Error
 	if err != nil {
 		return nil, err
 	}
 
+	if err := decryptContents(contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// GetStorageDataPage returns up to limit entries of a storage that come after
+// cursor, ordered by timestamp. The returned cursor points past the last entry
+// and is passed back to fetch the next page; hasMore reports whether one exists.
+func GetStorageDataPage(senderID, storageName string, cursor ContentCursor, limit int) (contents []models.Content, next ContentCursor, hasMore bool, err error) {
+	storageContent, err := storageByName(DB, senderID, storageName)
+	if err != nil {
+		return nil, cursor, false, err
+	}
+
+	query := DB.Where("storage_content_id = ?", storageContent.ID)
+	if !cursor.IsZero() {
+		query = query.Where("timestamp > ? OR (timestamp = ? AND id > ?)", cursor.Timestamp, cursor.Timestamp, cursor.ID)
+	}
+	// Fetch one extra row to learn whether another page follows.
+	err = query.Preload("Attachment").Preload("Tags").Order("timestamp, id").Limit(limit + 1).Find(&contents).Error
+	if err != nil {
+		return nil, cursor, false, err
+	}
+	if len(contents) > limit {
+		contents, hasMore = contents[:limit], true
+	}
+	if len(contents) > 0 {
+		last := contents[len(contents)-1]
+		cursor = ContentCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+
+	if err := decryptContents(contents); err != nil {
+		return nil, cursor, false, err
+	}
+	return contents, cursor, hasMore, nil
+}
+
+// GetStorageDataBetween returns the entries of a storage with a timestamp from
+// start up to but not including end, oldest first.
+func GetStorageDataBetween(senderID, storageName string, start, end time.Time) ([]models.Content, error) {
+	storageContent, err := storageByName(DB, senderID, storageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []models.Content
+	err = DB.Where("storage_content_id = ? AND timestamp >= ? AND timestamp < ?", storageContent.ID, start, end).
+		Order("timestamp, id").Find(&contents).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptContents(contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+func decryptContents(contents []models.Content) error {
 	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
 	for i, content := range contents {
 		decryptedData, err := utils.Decrypt(content.Data, encryptionKey)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		contents[i].Data = decryptedData
 	}
+	return nil
+}
 
-	log.Print(contents)
-	return contents, nil
+// migrations change existing rows after AutoMigrate, in order. Each runs
+// once and is then recorded as a SchemaMigration, so IDs must never change.
+var migrations = []struct {
+	id  string
+	run func(tx *gorm.DB) error
+}{
+	{"content_types", migrateContentTypes},
+	{"media_payloads", migrateMediaPayloads},
+}
+
+func runMigrations() error {
+	for _, migration := range migrations {
+		var done int64
+		if err := DB.Model(&models.SchemaMigration{}).Where("id = ?", migration.id).Count(&done).Error; err != nil {
+			return err
+		}
+		if done > 0 {
+			continue
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := migration.run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&models.SchemaMigration{ID: migration.id}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", migration.id, err)
+		}
+		log.Printf("Ran migration %s", migration.id)
+	}
+	return nil
+}
+
+// migrateContentTypes classifies entries stored before content types existed:
+// those with an attachment are media, everything else is text. Entries that
+// were media and have since been edited into text stay text.
+func migrateContentTypes(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	err := tx.Model(&models.Content{}).
+		Where("type = ? AND id IN (?) AND id NOT IN (?)", models.ContentTypeText,
+			db.Model(&models.Attachment{}).Select("content_id"),
+			db.Model(&models.ContentRevision{}).Where("type = ?", models.ContentTypeMedia).Select("content_id")).
+		Update("type", models.ContentTypeMedia).Error
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.Content{}).
+		Where("type = '' OR type IS NULL").
+		Update("type", models.ContentTypeText).Error
+}
+
+// migrateMediaPayloads replaces the English labels media entries used to
+// keep, such as "File: notes.pdf", with the MediaPayload of their
+// attachment, so the label can be shown in each reader's language.
+func migrateMediaPayloads(tx *gorm.DB) error {
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	var attachments []models.Attachment
+	return tx.Model(&models.Attachment{}).FindInBatches(&attachments, 100, func(batch *gorm.DB, _ int) error {
+		for _, attachment := range attachments {
+			payload, err := models.EncodePayload(attachment.Payload())
+			if err != nil {
+				return err
+			}
+			encryptedData, err := utils.Encrypt(payload, encryptionKey)
+			if err != nil {
+				return err
+			}
+			err = tx.Session(&gorm.Session{NewDB: true}).Model(&models.Content{}).
+				Where("id = ? AND type = ?", attachment.ContentID, models.ContentTypeMedia).
+				Update("data", encryptedData).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// CreateStorage adds a storage for the sender, whose name must not be taken
+// by their own storages or those shared with them.
+func CreateStorage(senderID, storageName string) (models.StorageContent, error) {
+	_, err := storageByName(DB, senderID, storageName)
+	if err == nil {
+		return models.StorageContent{}, ErrStorageExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.StorageContent{}, err
+	}
+
+	storageContent := models.StorageContent{
+		SenderID:    senderID,
+		StorageName: storageName,
+	}
+	if err := DB.Create(&storageContent).Error; err != nil {
+		return models.StorageContent{}, err
+	}
+	return storageContent, nil
+}
+
+// GetStorages returns all of a sender's storages, oldest first.
+func GetStorages(senderID string) ([]models.StorageContent, error) {
+	var storages []models.StorageContent
+	err := DB.Where("sender_id = ?", senderID).Order("id").Find(&storages).Error
+	return storages, err
+}
+
+// GetStorage returns a storage the sender owns or has been shared by ID.
+func GetStorage(senderID string, storageID uint) (models.StorageContent, error) {
+	return accessibleStorage(DB, senderID, storageID, false)
+}
+
+// RenameStorage gives one of the sender's storages a new name, which must not
+// belong to another storage they own or have been shared, nor to one that
+// anyone it is shared with has (ErrMemberHasName). The old name is kept in
+// the storage's rename history.
+func RenameStorage(senderID string, storageID uint, newName string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		storageContent, err := ownedStorage(tx, senderID, storageID)
+		if err != nil {
+			return err
+		}
+
+		existing, err := storageByName(tx, senderID, newName)
+		if err == nil && existing.ID != storageID {
+			return ErrStorageExists
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		taken, err := memberHasStorageNamed(tx, storageID, newName)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrMemberHasName
+		}
+
+		rename := models.StorageRename{
+			StorageContentID: storageID,
+			SenderID:         senderID,
+			OldName:          storageContent.StorageName,
+			NewName:          newName,
+		}
+		if err := tx.Create(&rename).Error; err != nil {
+			return err
+		}
+		return tx.Model(&storageContent).Update("storage_name", newName).Error
+	})
+}
+
+// GetStorageRenames returns the rename history of a storage the sender can
+// see, oldest first.
+func GetStorageRenames(senderID string, storageID uint) ([]models.StorageRename, error) {
+	if _, err := accessibleStorage(DB, senderID, storageID, false); err != nil {
+		return nil, err
+	}
+	var renames []models.StorageRename
+	err := DB.Where("storage_content_id = ?", storageID).Order("created_at, id").Find(&renames).Error
+	return renames, err
+}
+
+// SearchData returns every entry the sender can see whose decrypted text, as
+// shown in locale, contains query, ignoring case.
+func SearchData(senderID, locale, query string) ([]SearchResult, error) {
+	var storageContents []models.StorageContent
+	err := DB.Preload("Contents").Where("sender_id = ? OR id IN (?)", senderID, sharedWith(DB, senderID)).Find(&storageContents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	query = strings.ToLower(query)
+	results := []SearchResult{}
+	for _, storageContent := range storageContents {
+		for _, content := range storageContent.Contents {
+			decryptedData, err := utils.Decrypt(content.Data, encryptionKey)
+			if err != nil {
+				return nil, err
+			}
+			content.Data = decryptedData
+			if strings.Contains(strings.ToLower(content.Text(locale)), query) {
+				results = append(results, SearchResult{StorageName: storageContent.StorageName, Content: content})
+			}
+		}
+	}
+	return results, nil
+}
+
+// GetUserPreference returns the sender's preferences, or the defaults when
+// none have been saved yet.
+func GetUserPreference(senderID string) (models.UserPreference, error) {
+	preference := models.UserPreference{SenderID: senderID, ViewMode: models.ViewModeFull, Clock: models.Clock12, DateOrder: utils.DateOrderMDY}
+	err := DB.Where("sender_id = ?", senderID).First(&preference).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return preference, err
+	}
+	return preference, nil
+}
+
+func SaveUserPreference(preference models.UserPreference) error {
+	return DB.Save(&preference).Error
 }
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// CreateReminder schedules a reminder for one of the sender's entries, or for
+// the whole storage when contentID is 0.
+func CreateReminder(senderID string, storageID, contentID uint, dueAt time.Time) (models.Reminder, error) {
+	reminder := models.Reminder{SenderID: senderID, StorageContentID: storageID, DueAt: dueAt, Status: models.ReminderPending}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if contentID != 0 {
+			content, err := accessibleContent(tx, senderID, contentID, false)
+			if err != nil {
+				return err
+			}
+			reminder.StorageContentID = content.StorageContentID
+			reminder.ContentID = &content.ID
+		} else if _, err := accessibleStorage(tx, senderID, storageID, false); err != nil {
+			return err
+		}
+		return tx.Create(&reminder).Error
+	})
+	return reminder, err
+}
+
+// DueReminders returns up to limit pending reminders due at or before now,
+// oldest first. Reminders that fell due while the bot was down are included.
+func DueReminders(now time.Time, limit int) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := DB.Where("status = ? AND due_at <= ?", models.ReminderPending, now).Order("due_at, id").Limit(limit).Find(&reminders).Error
+	return reminders, err
+}
+
+// MarkReminderSent records that a reminder has been delivered.
+func MarkReminderSent(reminderID uint) error {
+	return DB.Model(&models.Reminder{}).Where("id = ? AND status = ?", reminderID, models.ReminderPending).Update("status", models.ReminderSent).Error
+}
+
+// GetReminders returns the sender's pending reminders, soonest first.
+func GetReminders(senderID string) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := DB.Where("sender_id = ? AND status = ?", senderID, models.ReminderPending).Order("due_at, id").Find(&reminders).Error
+	return reminders, err
+}
+
+func ownedReminder(tx *gorm.DB, senderID string, reminderID uint) (models.Reminder, error) {
+	var reminder models.Reminder
+	err := tx.Where("id = ? AND sender_id = ?", reminderID, senderID).First(&reminder).Error
+	return reminder, err
+}
+
+// SnoozeReminder makes one of the sender's reminders due again at dueAt.
+func SnoozeReminder(senderID string, reminderID uint, dueAt time.Time) error {
+	reminder, err := ownedReminder(DB, senderID, reminderID)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&reminder).Updates(map[string]interface{}{"status": models.ReminderPending, "due_at": dueAt}).Error
+}
+
+// DismissReminder stops one of the sender's reminders for good.
+func DismissReminder(senderID string, reminderID uint) error {
+	reminder, err := ownedReminder(DB, senderID, reminderID)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&reminder).Update("status", models.ReminderDismissed).Error
+}
+
+// deleteReminders removes the reminders of the given storages.
+func deleteReminders(tx *gorm.DB, storageIDs []uint) error {
+	return tx.Unscoped().Where("storage_content_id IN ?", storageIDs).Delete(&models.Reminder{}).Error
+}
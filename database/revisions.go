@@ -0,0 +1,121 @@
+package database
+
+import (
+	"os"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+// accessibleRevision loads a revision, still encrypted, provided the sender
+// can see its entry.
+func accessibleRevision(tx *gorm.DB, senderID string, revisionID uint) (models.ContentRevision, error) {
+	var revision models.ContentRevision
+	err := tx.First(&revision, revisionID).Error
+	if err != nil {
+		return models.ContentRevision{}, err
+	}
+	if _, err := accessibleContent(tx, senderID, revision.ContentID, false); err != nil {
+		return models.ContentRevision{}, err
+	}
+	return revision, nil
+}
+
+func decryptRevisions(revisions []models.ContentRevision) error {
+	encryptionKey := []byte(os.Getenv("ENCRYPTION_KEY"))
+	for i, revision := range revisions {
+		decryptedData, err := utils.Decrypt(revision.Data, encryptionKey)
+		if err != nil {
+			return err
+		}
+		revisions[i].Data = decryptedData
+	}
+	return nil
+}
+
+// GetContentRevisions returns the decrypted earlier versions of an entry the
+// sender can see, newest first.
+func GetContentRevisions(senderID string, contentID uint, limit int) ([]models.ContentRevision, error) {
+	if _, err := accessibleContent(DB, senderID, contentID, false); err != nil {
+		return nil, err
+	}
+
+	var revisions []models.ContentRevision
+	err := DB.Where("content_id = ?", contentID).Order("id DESC").Limit(limit).Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptRevisions(revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetContentRevision returns one decrypted revision of an entry the sender
+// can see.
+func GetContentRevision(senderID string, revisionID uint) (models.ContentRevision, error) {
+	revision, err := accessibleRevision(DB, senderID, revisionID)
+	if err != nil {
+		return models.ContentRevision{}, err
+	}
+	revisions := []models.ContentRevision{revision}
+	if err := decryptRevisions(revisions); err != nil {
+		return models.ContentRevision{}, err
+	}
+	return revisions[0], nil
+}
+
+// CompareContentRevisions returns two decrypted versions of the same entry,
+// older first: revision fromID and revision toID, or the entry's current data
+// when toID is 0.
+func CompareContentRevisions(senderID string, fromID, toID uint) (older, newer models.ContentRevision, err error) {
+	from, err := accessibleRevision(DB, senderID, fromID)
+	if err != nil {
+		return models.ContentRevision{}, models.ContentRevision{}, err
+	}
+
+	var to models.ContentRevision
+	if toID == 0 {
+		var content models.Content
+		if err := DB.First(&content, from.ContentID).Error; err != nil {
+			return models.ContentRevision{}, models.ContentRevision{}, err
+		}
+		to = models.ContentRevision{ContentID: content.ID, Timestamp: content.UpdatedAt, Type: content.Type, Data: content.Data}
+	} else {
+		err := DB.Where("id = ? AND content_id = ?", toID, from.ContentID).First(&to).Error
+		if err != nil {
+			return models.ContentRevision{}, models.ContentRevision{}, err
+		}
+		if to.ID < from.ID {
+			from, to = to, from
+		}
+	}
+
+	revisions := []models.ContentRevision{from, to}
+	if err := decryptRevisions(revisions); err != nil {
+		return models.ContentRevision{}, models.ContentRevision{}, err
+	}
+	return revisions[0], revisions[1], nil
+}
+
+// RestoreContentRevision makes a revision the entry's current text again. The
+// text it replaces becomes a revision itself, so a restore can be undone.
+func RestoreContentRevision(senderID string, revisionID uint) (models.Content, error) {
+	var content models.Content
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		revision, err := accessibleRevision(tx, senderID, revisionID)
+		if err != nil {
+			return err
+		}
+		content, err = accessibleContent(tx, senderID, revision.ContentID, true)
+		if err != nil {
+			return err
+		}
+		if content.Type == models.ContentTypeMedia {
+			return ErrMediaEntry
+		}
+		return replaceContentData(tx, content, revision.Type, revision.Data)
+	})
+	return content, err
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReadOnly      = errors.New("storage is shared read-only")
+	ErrInvalidInvite = errors.New("invite code is unknown or expired")
+	ErrOwnStorage    = errors.New("storage already belongs to the sender")
+	ErrMemberHasName = errors.New("a member already has a storage with that name")
+)
+
+// RoleOwner is what StorageRole reports for the sender's own storages.
+const RoleOwner = "owner"
+
+// inviteAlphabet leaves out characters that are easy to mistype.
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// sharedWith selects the IDs of the storages shared with the sender.
+func sharedWith(tx *gorm.DB, senderID string) *gorm.DB {
+	return tx.Model(&models.StorageShare{}).Select("storage_content_id").Where("sender_id = ?", senderID)
+}
+
+// accessibleStorage loads a storage the sender owns or has been shared. With
+// write set, viewers get ErrReadOnly.
+func accessibleStorage(tx *gorm.DB, senderID string, storageID uint, write bool) (models.StorageContent, error) {
+	var storageContent models.StorageContent
+	if err := tx.First(&storageContent, storageID).Error; err != nil {
+		return models.StorageContent{}, err
+	}
+	if storageContent.SenderID == senderID {
+		return storageContent, nil
+	}
+	var share models.StorageShare
+	err := tx.Where("storage_content_id = ? AND sender_id = ?", storageID, senderID).First(&share).Error
+	if err != nil {
+		return models.StorageContent{}, err
+	}
+	if write && share.Role != models.ShareRoleContributor {
+		return models.StorageContent{}, ErrReadOnly
+	}
+	return storageContent, nil
+}
+
+// accessibleContent loads an entry of a storage the sender owns or has been
+// shared. With write set, viewers get ErrReadOnly.
+func accessibleContent(tx *gorm.DB, senderID string, contentID uint, write bool) (models.Content, error) {
+	var content models.Content
+	err := tx.Joins("JOIN storage_contents ON storage_contents.id = contents.storage_content_id AND storage_contents.deleted_at IS NULL").
+		Where("contents.id = ?", contentID).
+		First(&content).Error
+	if err != nil {
+		return models.Content{}, err
+	}
+	if _, err := accessibleStorage(tx.Session(&gorm.Session{NewDB: true}), senderID, content.StorageContentID, write); err != nil {
+		return models.Content{}, err
+	}
+	return content, nil
+}
+
+// memberHasStorageNamed reports whether anyone a storage is shared with
+// already has another storage called name, of their own or shared with them,
+// which would hide it from them by name.
+func memberHasStorageNamed(tx *gorm.DB, storageID uint, name string) (bool, error) {
+	members := tx.Model(&models.StorageShare{}).Select("sender_id").Where("storage_content_id = ?", storageID)
+	sharedWithMembers := tx.Model(&models.StorageShare{}).Select("storage_content_id").Where("sender_id IN (?)", members)
+	var count int64
+	err := tx.Model(&models.StorageContent{}).
+		Where("storage_name = ? AND id <> ? AND (sender_id IN (?) OR id IN (?))", name, storageID, members, sharedWithMembers).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// storageByName finds the storage the sender calls storageName: their own
+// first, then one shared with them.
+func storageByName(tx *gorm.DB, senderID, storageName string) (models.StorageContent, error) {
+	var storageContent models.StorageContent
+	err := tx.Where("sender_id = ? AND storage_name = ?", senderID, storageName).First(&storageContent).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return storageContent, err
+	}
+	err = tx.Where("id IN (?) AND storage_name = ?", sharedWith(tx, senderID), storageName).First(&storageContent).Error
+	return storageContent, err
+}
+
+// writableStorageByName is storageByName for adding entries, which viewers
+// may not.
+func writableStorageByName(tx *gorm.DB, senderID, storageName string) (models.StorageContent, error) {
+	storageContent, err := storageByName(tx, senderID, storageName)
+	if err != nil {
+		return storageContent, err
+	}
+	return accessibleStorage(tx, senderID, storageContent.ID, true)
+}
+
+// StorageRole reports the sender's role on a storage: RoleOwner or one of
+// the share roles.
+func StorageRole(senderID string, storageID uint) (string, error) {
+	storageContent, err := accessibleStorage(DB, senderID, storageID, false)
+	if err != nil {
+		return "", err
+	}
+	if storageContent.SenderID == senderID {
+		return RoleOwner, nil
+	}
+	var share models.StorageShare
+	err = DB.Where("storage_content_id = ? AND sender_id = ?", storageID, senderID).First(&share).Error
+	return share.Role, err
+}
+
+// GetSharedStorages returns the storages other senders shared with the
+// sender, oldest share first. They are shown at the sender's top level, so
+// ParentID is cleared.
+func GetSharedStorages(senderID string) ([]models.StorageContent, error) {
+	var storages []models.StorageContent
+	err := DB.Joins("JOIN storage_shares ON storage_shares.storage_content_id = storage_contents.id AND storage_shares.deleted_at IS NULL").
+		Where("storage_shares.sender_id = ?", senderID).
+		Order("storage_shares.id").
+		Find(&storages).Error
+	for i := range storages {
+		storages[i].ParentID = nil
+	}
+	return storages, err
+}
+
+// CreateShareInvite makes a single-use invite code for one of the sender's
+// storages, valid for ttl.
+func CreateShareInvite(senderID string, storageID uint, role string, ttl time.Duration) (models.ShareInvite, error) {
+	if _, err := ownedStorage(DB, senderID, storageID); err != nil {
+		return models.ShareInvite{}, err
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return models.ShareInvite{}, err
+	}
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	invite := models.ShareInvite{Code: string(b), StorageContentID: storageID, Role: role, ExpiresAt: time.Now().Add(ttl)}
+	err := DB.Create(&invite).Error
+	return invite, err
+}
+
+// RedeemShareInvite gives the sender the access an invite code grants and
+// uses the code up. It fails with ErrStorageExists when the sender already
+// has a storage by the shared storage's name.
+func RedeemShareInvite(senderID, code string) (models.StorageContent, models.StorageShare, error) {
+	var storageContent models.StorageContent
+	var share models.StorageShare
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invite models.ShareInvite
+		err := tx.Where("code = ? AND expires_at > ?", strings.ToUpper(strings.TrimSpace(code)), time.Now()).First(&invite).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidInvite
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&storageContent, invite.StorageContentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidInvite
+			}
+			return err
+		}
+		if storageContent.SenderID == senderID {
+			return ErrOwnStorage
+		}
+
+		err = tx.Where("storage_content_id = ? AND sender_id = ?", storageContent.ID, senderID).First(&share).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existing, err := storageByName(tx, senderID, storageContent.StorageName)
+			if err == nil && existing.ID != storageContent.ID {
+				return ErrStorageExists
+			}
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			share = models.StorageShare{StorageContentID: storageContent.ID, SenderID: senderID, Role: invite.Role}
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if err := tx.Model(&share).Update("role", invite.Role).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&invite).Error
+	})
+	storageContent.ParentID = nil
+	return storageContent, share, err
+}
+
+// GetStorageShares lists who one of the sender's storages is shared with.
+func GetStorageShares(senderID string, storageID uint) ([]models.StorageShare, error) {
+	if _, err := ownedStorage(DB, senderID, storageID); err != nil {
+		return nil, err
+	}
+	var shares []models.StorageShare
+	err := DB.Where("storage_content_id = ?", storageID).Order("id").Find(&shares).Error
+	return shares, err
+}
+
+// RevokeShare takes back access to one of the sender's storages and returns
+// the share that was removed.
+func RevokeShare(senderID string, shareID uint) (models.StorageShare, error) {
+	var share models.StorageShare
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&share, shareID).Error; err != nil {
+			return err
+		}
+		if _, err := ownedStorage(tx, senderID, share.StorageContentID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&share).Error
+	})
+	return share, err
+}
+
+// LeaveShare gives up the sender's access to a storage shared with them.
+func LeaveShare(senderID string, storageID uint) error {
+	result := DB.Unscoped().Where("storage_content_id = ? AND sender_id = ?", storageID, senderID).Delete(&models.StorageShare{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// StorageMembers returns the owner of a storage and everyone it is shared
+// with.
+func StorageMembers(storageID uint) ([]string, error) {
+	var storageContent models.StorageContent
+	if err := DB.First(&storageContent, storageID).Error; err != nil {
+		return nil, err
+	}
+	var members []string
+	if err := DB.Model(&models.StorageShare{}).Where("storage_content_id = ?", storageID).Order("id").Pluck("sender_id", &members).Error; err != nil {
+		return nil, err
+	}
+	return append([]string{storageContent.SenderID}, members...), nil
+}
+
+// deleteShares removes the shares and invites of the given storages.
+func deleteShares(tx *gorm.DB, storageIDs []uint) error {
+	if err := tx.Unscoped().Where("storage_content_id IN ?", storageIDs).Delete(&models.StorageShare{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("storage_content_id IN ?", storageIDs).Delete(&models.ShareInvite{}).Error
+}
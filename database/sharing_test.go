@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// testTx opens the MySQL database named by TEST_DSN and returns a
+// transaction that is rolled back when the test ends. Tests that need it are
+// skipped without one.
+func testTx(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DSN isn't set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.StorageContent{}, &models.StorageShare{}); err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+func TestMemberHasStorageNamed(t *testing.T) {
+	tx := testTx(t)
+	// Sender IDs unique to this run keep rows already in the database out
+	// of the counts.
+	id := func(name string) string { return fmt.Sprintf("test-%s-%d", name, time.Now().UnixNano()) }
+	owner, member, other, stranger := id("owner"), id("member"), id("other"), id("stranger")
+
+	create := func(senderID, name string) models.StorageContent {
+		t.Helper()
+		storage := models.StorageContent{SenderID: senderID, StorageName: name}
+		if err := tx.Create(&storage).Error; err != nil {
+			t.Fatal(err)
+		}
+		return storage
+	}
+	share := func(storage models.StorageContent, senderID string) {
+		t.Helper()
+		if err := tx.Create(&models.StorageShare{StorageContentID: storage.ID, SenderID: senderID, Role: models.ShareRoleViewer}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trips := create(owner, "Trips")
+	share(trips, member)
+	create(member, "Holidays")
+	share(create(other, "Recipes"), member)
+	create(stranger, "Books")
+	private := create(owner, "Private")
+
+	tests := []struct {
+		storage models.StorageContent
+		name    string
+		want    bool
+	}{
+		{trips, "Holidays", true},    // the member's own storage
+		{trips, "Recipes", true},     // another storage shared with the member
+		{trips, "Books", false},      // only someone without access has one
+		{trips, "Trips", false},      // the storage itself
+		{trips, "Private", false},    // the owner's own, checked elsewhere
+		{private, "Holidays", false}, // not shared with anyone
+	}
+	for _, tt := range tests {
+		got, err := memberHasStorageNamed(tx, tt.storage.ID, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("renaming %q to %q: member has the name = %t, want %t", tt.storage.StorageName, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// tagContent links a content to the sender's tags with the given names,
+// creating tags that don't exist yet.
+func tagContent(tx *gorm.DB, senderID string, content models.Content, names []string) error {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		name = utils.NormalizeTag(name)
+		if name == "" {
+			continue
+		}
+		var tag models.Tag
+		if err := tx.Where(models.Tag{SenderID: senderID, Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tx.Model(&content).Association("Tags").Append(tags)
+}
+
+// AddTags attaches tags to one of the sender's entries.
+func AddTags(senderID string, contentID uint, names []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx, senderID, contentID, true)
+		if err != nil {
+			return err
+		}
+		return tagContent(tx, senderID, content, names)
+	})
+}
+
+// GetTags lists the sender's tags that are on at least one entry, with how
+// many entries carry each, most used first.
+func GetTags(senderID string) ([]TagCount, error) {
+	var counts []TagCount
+	err := DB.Model(&models.Tag{}).
+		Select("tags.name AS name, COUNT(content_tags.content_id) AS count").
+		Joins("JOIN content_tags ON content_tags.tag_id = tags.id").
+		Where("tags.sender_id = ?", senderID).
+		Group("tags.name").
+		Order("count DESC, tags.name").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// GetDataByTag returns the decrypted entries the sender tagged with tag,
+// across all storages they can see, ordered by timestamp.
+func GetDataByTag(senderID, tag string) ([]SearchResult, error) {
+	var contents []models.Content
+	err := DB.Joins("JOIN storage_contents ON storage_contents.id = contents.storage_content_id AND storage_contents.deleted_at IS NULL").
+		Joins("JOIN content_tags ON content_tags.content_id = contents.id").
+		Joins("JOIN tags ON tags.id = content_tags.tag_id").
+		Where("(storage_contents.sender_id = ? OR storage_contents.id IN (?)) AND tags.sender_id = ? AND tags.name = ?", senderID, sharedWith(DB, senderID), senderID, utils.NormalizeTag(tag)).
+		Order("contents.timestamp, contents.id").
+		Find(&contents).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptContents(contents); err != nil {
+		return nil, err
+	}
+
+	var storageContents []models.StorageContent
+	if err := DB.Where("sender_id = ? OR id IN (?)", senderID, sharedWith(DB, senderID)).Find(&storageContents).Error; err != nil {
+		return nil, err
+	}
+	storageNames := make(map[uint]string, len(storageContents))
+	for _, storageContent := range storageContents {
+		storageNames[storageContent.ID] = storageContent.StorageName
+	}
+
+	results := make([]SearchResult, len(contents))
+	for i, content := range contents {
+		results[i] = SearchResult{StorageName: storageNames[content.StorageContentID], Content: content}
+	}
+	return results, nil
+}
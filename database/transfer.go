@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+
+	"github.com/markDoesany/quickymessenger/blobstore"
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// ownedStorage loads one of the sender's storages by ID.
+func ownedStorage(tx *gorm.DB, senderID string, storageID uint) (models.StorageContent, error) {
+	var storageContent models.StorageContent
+	err := tx.Where("id = ? AND sender_id = ?", storageID, senderID).First(&storageContent).Error
+	return storageContent, err
+}
+
+// MoveContent moves an entry into another storage; the sender must be able to
+// change both storages. Entries are encrypted with the same key whatever storage they are
+// in, so the data moves as it is, along with its history and tags.
+func MoveContent(senderID string, contentID, storageID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx, senderID, contentID, true)
+		if err != nil {
+			return err
+		}
+		if _, err := accessibleStorage(tx, senderID, storageID, true); err != nil {
+			return err
+		}
+		if err := tx.Model(&content).Update("storage_content_id", storageID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Reminder{}).Where("content_id = ?", content.ID).Update("storage_content_id", storageID).Error
+	})
+}
+
+// CopyContent adds a copy of an entry the sender can see, with its tags and
+// attached file, to a storage they can change. The copy starts without
+// history.
+func CopyContent(senderID string, contentID, storageID uint) error {
+	var blobKey string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		content, err := accessibleContent(tx.Preload("Attachment").Preload("Tags"), senderID, contentID, false)
+		if err != nil {
+			return err
+		}
+		if _, err := accessibleStorage(tx, senderID, storageID, true); err != nil {
+			return err
+		}
+
+		copied := models.Content{
+			StorageContentID: storageID,
+			Timestamp:        content.Timestamp,
+			Type:             content.Type,
+			Data:             content.Data,
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return err
+		}
+		if len(content.Tags) > 0 {
+			if err := tx.Model(&copied).Association("Tags").Append(content.Tags); err != nil {
+				return err
+			}
+		}
+		if content.Attachment == nil {
+			return nil
+		}
+
+		// Each attachment owns its blob, so the file is copied too. It is
+		// still encrypted and is copied without decrypting it.
+		blobKey, err = copyBlob(content.Attachment.BlobKey)
+		if err != nil {
+			return err
+		}
+		attachment := *content.Attachment
+		attachment.ID = 0
+		attachment.ContentID = copied.ID
+		attachment.BlobKey = blobKey
+		return tx.Create(&attachment).Error
+	})
+	if err != nil && blobKey != "" {
+		deleteBlobs([]string{blobKey})
+	}
+	return err
+}
+
+func copyBlob(key string) (string, error) {
+	data, err := blobstore.Default.Get(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	newKey, err := newBlobKey()
+	if err != nil {
+		return "", err
+	}
+	if err := blobstore.Default.Put(context.Background(), newKey, data); err != nil {
+		return "", err
+	}
+	return newKey, nil
+}
+
+// MergeStorage moves every entry and nested storage of one of the sender's
+// storages into another, then removes the emptied storage.
+func MergeStorage(senderID string, sourceID, targetID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		tree, err := storageTree(tx, senderID, sourceID)
+		if err != nil {
+			return err
+		}
+		for _, id := range tree {
+			if id == targetID {
+				return ErrInvalidMove
+			}
+		}
+		if _, err := ownedStorage(tx, senderID, targetID); err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Content{}).Where("storage_content_id = ?", sourceID).Update("storage_content_id", targetID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.Reminder{}).Where("storage_content_id = ?", sourceID).Update("storage_content_id", targetID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.StorageContent{}).Where("parent_id = ? AND sender_id = ?", sourceID, senderID).Update("parent_id", targetID).Error
+		if err != nil {
+			return err
+		}
+		if err := deleteShares(tx, []uint{sourceID}); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("storage_content_id = ?", sourceID).Delete(&models.StorageRename{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.StorageContent{}, sourceID).Error
+	})
+}
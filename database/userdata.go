@@ -0,0 +1,210 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// StorageSummary is one storage in a DataSummary.
+type StorageSummary struct {
+	Name    string
+	Entries int
+}
+
+// DataSummary counts everything stored about a sender.
+type DataSummary struct {
+	Storages  []StorageSummary
+	Entries   int
+	Files     int
+	FileBytes int64
+	Revisions int
+	Tags      int
+	Reminders int
+	Renames   int
+	// Shares counts the storages shared with the sender and the people
+	// their own storages are shared with.
+	Shares      int
+	Preferences bool
+}
+
+// Empty reports whether nothing is stored about the sender.
+func (s DataSummary) Empty() bool {
+	return len(s.Storages) == 0 && s.Tags == 0 && s.Reminders == 0 && s.Renames == 0 && s.Shares == 0 && !s.Preferences
+}
+
+// SenderHash is the keyed hash deletion records use in place of the sender ID.
+func SenderHash(senderID string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("ENCRYPTION_KEY")))
+	mac.Write([]byte(senderID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetDataSummary lists the sender's storages with how many entries each
+// holds, and counts the rest of what is stored about them.
+func GetDataSummary(senderID string) (DataSummary, error) {
+	return summarize(DB, senderID)
+}
+
+func summarize(tx *gorm.DB, senderID string) (DataSummary, error) {
+	var summary DataSummary
+	var storages []models.StorageContent
+	if err := tx.Where("sender_id = ?", senderID).Order("id").Find(&storages).Error; err != nil {
+		return summary, err
+	}
+	storageIDs := make([]uint, len(storages))
+	for i, storage := range storages {
+		storageIDs[i] = storage.ID
+	}
+
+	entries := map[uint]int{}
+	if len(storageIDs) > 0 {
+		var counts []struct {
+			StorageContentID uint
+			Count            int
+		}
+		err := tx.Model(&models.Content{}).Select("storage_content_id, COUNT(*) AS count").
+			Where("storage_content_id IN ?", storageIDs).Group("storage_content_id").Scan(&counts).Error
+		if err != nil {
+			return summary, err
+		}
+		for _, c := range counts {
+			entries[c.StorageContentID] = c.Count
+		}
+
+		contentIDs := tx.Model(&models.Content{}).Select("id").Where("storage_content_id IN ?", storageIDs)
+		var files struct {
+			Count int
+			Bytes int64
+		}
+		err = tx.Model(&models.Attachment{}).Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS bytes").
+			Where("content_id IN (?)", contentIDs).Scan(&files).Error
+		if err != nil {
+			return summary, err
+		}
+		summary.Files, summary.FileBytes = files.Count, files.Bytes
+
+		var revisions int64
+		if err := tx.Model(&models.ContentRevision{}).Where("content_id IN (?)", contentIDs).Count(&revisions).Error; err != nil {
+			return summary, err
+		}
+		summary.Revisions = int(revisions)
+	}
+	for _, storage := range storages {
+		summary.Storages = append(summary.Storages, StorageSummary{Name: storage.StorageName, Entries: entries[storage.ID]})
+		summary.Entries += entries[storage.ID]
+	}
+
+	counts := []struct {
+		model interface{}
+		count *int
+	}{
+		{&models.Tag{}, &summary.Tags},
+		{&models.Reminder{}, &summary.Reminders},
+		{&models.StorageRename{}, &summary.Renames},
+	}
+	for _, c := range counts {
+		var count int64
+		if err := tx.Model(c.model).Where("sender_id = ?", senderID).Count(&count).Error; err != nil {
+			return summary, err
+		}
+		*c.count = int(count)
+	}
+	var shares int64
+	err := tx.Model(&models.StorageShare{}).
+		Where("sender_id = ? OR storage_content_id IN (?)", senderID, tx.Model(&models.StorageContent{}).Select("id").Where("sender_id = ?", senderID)).
+		Count(&shares).Error
+	if err != nil {
+		return summary, err
+	}
+	summary.Shares = int(shares)
+
+	var preferences int64
+	if err := tx.Model(&models.UserPreference{}).Where("sender_id = ?", senderID).Count(&preferences).Error; err != nil {
+		return summary, err
+	}
+	summary.Preferences = preferences > 0
+	return summary, nil
+}
+
+// DeleteUserData removes everything stored about a sender (storages, entries
+// with their files, revisions and reminders, tags, renames, shares and
+// preferences), including reminders other members set on the sender's
+// storages, and leaves a DeletionRecord saying it happened. It returns
+// what was deleted.
+func DeleteUserData(senderID, source string) (DataSummary, error) {
+	var summary DataSummary
+	var blobKeys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		summary, err = summarize(tx, senderID)
+		if err != nil {
+			return err
+		}
+
+		var storageIDs []uint
+		if err := tx.Model(&models.StorageContent{}).Where("sender_id = ?", senderID).Pluck("id", &storageIDs).Error; err != nil {
+			return err
+		}
+		if len(storageIDs) > 0 {
+			var contentIDs []uint
+			if err := tx.Model(&models.Content{}).Where("storage_content_id IN ?", storageIDs).Pluck("id", &contentIDs).Error; err != nil {
+				return err
+			}
+			blobKeys, err = deleteContentRows(tx, contentIDs)
+			if err != nil {
+				return err
+			}
+			if err := deleteReminders(tx, storageIDs); err != nil {
+				return err
+			}
+			if err := deleteShares(tx, storageIDs); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", storageIDs).Delete(&models.StorageContent{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var tagIDs []uint
+		if err := tx.Model(&models.Tag{}).Where("sender_id = ?", senderID).Pluck("id", &tagIDs).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) > 0 {
+			if err := tx.Exec("DELETE FROM content_tags WHERE tag_id IN ?", tagIDs).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{&models.Tag{}, &models.Reminder{}, &models.StorageRename{}, &models.StorageShare{}, &models.UserPreference{}} {
+			if err := tx.Unscoped().Where("sender_id = ?", senderID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.DeletionRecord{
+			SenderHash: SenderHash(senderID),
+			Source:     source,
+			Storages:   len(summary.Storages),
+			Entries:    summary.Entries,
+			Files:      summary.Files,
+		}).Error
+	})
+	if err != nil {
+		return DataSummary{}, err
+	}
+	deleteBlobs(blobKeys)
+	return summary, nil
+}
+
+// DeletedSince reports whether the sender's data was deleted after since,
+// such as by an admin while the bot had them cached.
+func DeletedSince(senderID string, since time.Time) (bool, error) {
+	var count int64
+	err := DB.Model(&models.DeletionRecord{}).Where("sender_hash = ? AND created_at > ?", SenderHash(senderID), since).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,521 @@
+// Package dateparse reads everyday date expressions such as "next Friday at
+// 6pm", "in 2 hours", "tomorrow morning" or "last week". Results depend only
+// on the text, the locale's rules and the reference time passed in, so the
+// same input always gives the same answer.
+package dateparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnrecognized = errors.New("unrecognized date expression")
+
+// Direction settles expressions that could mean either side of the reference
+// time, such as a bare "friday" or "9am".
+type Direction int
+
+const (
+	// Future reads them as the next such time, for reminders.
+	Future Direction = iota
+	// Past reads them as the latest such time, for searching what was stored.
+	Past
+)
+
+// Result is the period an expression names, from Start up to but not
+// including End. Expressions down to the minute, such as "tomorrow 9am" or
+// "in 2 hours", name a moment: Start and End are equal and HasTime is set.
+type Result struct {
+	Start   time.Time
+	End     time.Time
+	HasTime bool
+}
+
+// Parser reads expressions using one locale's rules.
+type Parser struct {
+	Rules *Rules
+	// Location is where expressions are read; nil uses the reference time's.
+	Location *time.Location
+	Prefer   Direction
+}
+
+// New returns a parser for a locale such as "en", "en-GB" or "es".
+func New(locale string, location *time.Location, prefer Direction) *Parser {
+	return &Parser{Rules: Locale(locale), Location: location, Prefer: prefer}
+}
+
+var (
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	ordinalPattern = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)$`)
+	numDatePattern = regexp.MustCompile(`^(\d{1,2})[/.](\d{1,2})(?:[/.](\d{2}|\d{4}))?$`)
+	yearPattern    = regexp.MustCompile(`^\d{4}$`)
+	accents        = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "february": time.February, "march": time.March, "april": time.April,
+	"may": time.May, "june": time.June, "july": time.July, "august": time.August,
+	"september": time.September, "october": time.October, "november": time.November, "december": time.December,
+}
+
+// dayParts are the hours used for "morning" and the like when no time is given.
+var dayParts = map[string]int{"morning": 9, "afternoon": 15, "evening": 19, "tonight": 20}
+
+var units = map[string]bool{"minute": true, "hour": true, "day": true, "week": true, "month": true, "year": true}
+
+var modifiers = map[string]bool{"next": true, "last": true, "this": true}
+
+// tokens turns text into canonical tokens, replacing the locale's words and
+// phrases. Numbers, dates and times are kept as written.
+func (p *Parser) tokens(text string) []string {
+	text = accents.Replace(strings.ToLower(text))
+	text = strings.NewReplacer(",", " ", ";", " ", "?", " ", "!", " ").Replace(text)
+	words := strings.Fields(text)
+	for i, word := range words {
+		if _, ok := p.Rules.Words[word]; !ok {
+			word = strings.TrimSuffix(word, ".")
+		}
+		words[i] = ordinalPattern.ReplaceAllString(word, "$1")
+	}
+
+	phrases := make([][]string, 0, len(p.Rules.Phrases))
+	for phrase := range p.Rules.Phrases {
+		phrases = append(phrases, strings.Fields(phrase))
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if len(phrases[i]) != len(phrases[j]) {
+			return len(phrases[i]) > len(phrases[j])
+		}
+		return strings.Join(phrases[i], " ") < strings.Join(phrases[j], " ")
+	})
+
+	tokens := []string{}
+	for i := 0; i < len(words); {
+		matched := false
+		for _, phrase := range phrases {
+			if i+len(phrase) <= len(words) && equalWords(words[i:i+len(phrase)], phrase) {
+				tokens = append(tokens, strings.Fields(p.Rules.Phrases[strings.Join(phrase, " ")])...)
+				i += len(phrase)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		word := words[i]
+		if canonical, ok := p.Rules.Words[word]; ok {
+			if canonical != "" {
+				tokens = append(tokens, canonical)
+			}
+		} else if n, ok := p.Rules.Numbers[word]; ok {
+			tokens = append(tokens, strconv.Itoa(n))
+		} else {
+			tokens = append(tokens, word)
+		}
+		i++
+	}
+	return tokens
+}
+
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// state collects what the tokens of an expression say.
+type state struct {
+	ref      time.Time
+	today    time.Time
+	date     time.Time
+	hasDate  bool
+	period   string
+	hour     int
+	minute   int
+	hasTime  bool
+	exact    time.Time
+	hasExact bool
+	dayPart  string
+}
+
+func (s *state) setDate(date time.Time, period string) error {
+	if s.hasDate || s.hasExact {
+		return ErrUnrecognized
+	}
+	s.date, s.period, s.hasDate = date, period, true
+	return nil
+}
+
+func (s *state) setTime(hour, minute int) error {
+	if s.hasTime || s.hasExact || hour > 23 || minute > 59 {
+		return ErrUnrecognized
+	}
+	s.hour, s.minute, s.hasTime = hour, minute, true
+	return nil
+}
+
+// Parse reads text relative to ref.
+func (p *Parser) Parse(text string, ref time.Time) (Result, error) {
+	if p.Location != nil {
+		ref = ref.In(p.Location)
+	}
+	s := &state{ref: ref, today: time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())}
+
+	tokens := p.tokens(text)
+	if len(tokens) == 0 {
+		return Result{}, ErrUnrecognized
+	}
+	for i := 0; i < len(tokens); {
+		n, err := p.parseToken(s, tokens, i)
+		if err != nil {
+			return Result{}, fmt.Errorf("%w: %q", err, text)
+		}
+		i += n
+	}
+	result, err := p.result(s)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %q", err, text)
+	}
+	return result, nil
+}
+
+func at(tokens []string, i int) string {
+	if i < len(tokens) {
+		return tokens[i]
+	}
+	return ""
+}
+
+func number(token string) (int, bool) {
+	n, err := strconv.Atoi(token)
+	return n, err == nil && n >= 0
+}
+
+// parseToken reads the expression part starting at tokens[i] and returns how
+// many tokens it used.
+func (p *Parser) parseToken(s *state, tokens []string, i int) (int, error) {
+	token := tokens[i]
+	next := at(tokens, i+1)
+
+	switch {
+	case token == "in":
+		// "in 2 hours"
+		n, ok := number(next)
+		if !ok || !units[at(tokens, i+2)] {
+			return 0, ErrUnrecognized
+		}
+		return 3, p.shift(s, n, at(tokens, i+2))
+	case token == "ago":
+		// "hace 3 dias"
+		n, ok := number(next)
+		if !ok || !units[at(tokens, i+2)] {
+			return 0, ErrUnrecognized
+		}
+		return 3, p.shift(s, -n, at(tokens, i+2))
+	case token == "today", token == "tomorrow", token == "yesterday", token == "overmorrow", token == "ereyesterday":
+		offsets := map[string]int{"today": 0, "tomorrow": 1, "yesterday": -1, "overmorrow": 2, "ereyesterday": -2}
+		return 1, s.setDate(s.today.AddDate(0, 0, offsets[token]), "day")
+	case modifiers[token]:
+		// "next friday", "last week", "this morning"
+		if _, ok := dayParts[next]; ok && token == "this" {
+			return 1, s.setDate(s.today, "day")
+		}
+		return 2, p.relative(s, next, token)
+	case weekdays[token] != 0 || token == "sunday", units[token]:
+		// "friday", or postfix modifiers as in "semana pasada"
+		if modifiers[next] {
+			return 2, p.relative(s, token, next)
+		}
+		if units[token] {
+			return 0, ErrUnrecognized
+		}
+		return 1, p.relative(s, token, "")
+	case months[token] != 0:
+		return p.monthDate(s, tokens, i, 0)
+	case token == "at":
+		if next == "noon" || next == "midnight" {
+			return 1, nil
+		}
+		n, err := p.clock(s, tokens, i+1, true)
+		return n + 1, err
+	case token == "noon":
+		return 1, s.setTime(12, 0)
+	case token == "midnight":
+		return 1, s.setTime(0, 0)
+	case dayParts[token] != 0:
+		if s.dayPart != "" {
+			return 0, ErrUnrecognized
+		}
+		s.dayPart = token
+		return 1, nil
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(token); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		return 1, p.setDay(s, year, month, day)
+	}
+	if m := numDatePattern.FindStringSubmatch(token); m != nil {
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		month, day := first, second
+		if p.Rules.DayFirst {
+			month, day = second, first
+		}
+		year := 0
+		if m[3] != "" {
+			year, _ = strconv.Atoi(m[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		return 1, p.setDay(s, year, month, day)
+	}
+
+	if n, ok := number(token); ok && !strings.Contains(token, ":") {
+		// "3 days ago", "3 june"
+		if units[next] && at(tokens, i+2) == "ago" {
+			return 3, p.shift(s, -n, next)
+		}
+		if months[next] != 0 {
+			used, err := p.monthDate(s, tokens, i+1, n)
+			return used + 1, err
+		}
+	}
+	return p.clock(s, tokens, i, false)
+}
+
+// clock reads a time of day such as "6pm", "6 pm", "18:30" or, when a date
+// or "at" makes it clear, a bare hour.
+func (p *Parser) clock(s *state, tokens []string, i int, afterAt bool) (int, error) {
+	m := clockPattern.FindStringSubmatch(at(tokens, i))
+	if m == nil {
+		return 0, ErrUnrecognized
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	suffix, used := m[3], 1
+	if suffix == "" && (at(tokens, i+1) == "am" || at(tokens, i+1) == "pm") {
+		suffix, used = at(tokens, i+1), 2
+	}
+	_, partFollows := dayParts[at(tokens, i+1)]
+	if suffix == "" && m[2] == "" && !afterAt && !s.hasDate && !partFollows {
+		return 0, ErrUnrecognized
+	}
+
+	if suffix != "" {
+		if hour < 1 || hour > 12 {
+			return 0, ErrUnrecognized
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	}
+	return used, s.setTime(hour, minute)
+}
+
+// shift moves n units from the reference time. Minutes and hours give a
+// moment; longer units give a day.
+func (p *Parser) shift(s *state, n int, unit string) error {
+	switch unit {
+	case "minute", "hour":
+		if s.hasDate || s.hasTime || s.hasExact {
+			return ErrUnrecognized
+		}
+		d := time.Minute
+		if unit == "hour" {
+			d = time.Hour
+		}
+		s.exact, s.hasExact = s.ref.Add(time.Duration(n)*d), true
+		return nil
+	case "day":
+		return s.setDate(s.today.AddDate(0, 0, n), "day")
+	case "week":
+		return s.setDate(s.today.AddDate(0, 0, 7*n), "day")
+	case "month":
+		return s.setDate(s.today.AddDate(0, n, 0), "day")
+	default:
+		return s.setDate(s.today.AddDate(n, 0, 0), "day")
+	}
+}
+
+func (p *Parser) startOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(p.Rules.WeekStart) + 7) % 7))
+}
+
+// relative reads a weekday or unit with an optional next, last or this.
+func (p *Parser) relative(s *state, target, modifier string) error {
+	if weekday, ok := weekdays[target]; ok {
+		ahead := (int(weekday) - int(s.today.Weekday()) + 7) % 7
+		behind := (int(s.today.Weekday()) - int(weekday) + 7) % 7
+		var offset int
+		switch {
+		case modifier == "next" && ahead == 0:
+			offset = 7
+		case modifier == "next":
+			offset = ahead
+		case modifier == "last" && behind == 0:
+			offset = -7
+		case modifier == "last":
+			offset = -behind
+		case modifier == "this":
+			week := p.startOfWeek(s.today)
+			return s.setDate(week.AddDate(0, 0, (int(weekday)-int(p.Rules.WeekStart)+7)%7), "day")
+		case p.Prefer == Past:
+			offset = -behind
+		default:
+			offset = ahead
+		}
+		return s.setDate(s.today.AddDate(0, 0, offset), "day")
+	}
+
+	step := map[string]int{"next": 1, "last": -1, "this": 0}[modifier]
+	switch target {
+	case "day":
+		return s.setDate(s.today.AddDate(0, 0, step), "day")
+	case "week":
+		return s.setDate(p.startOfWeek(s.today).AddDate(0, 0, 7*step), "week")
+	case "month":
+		first := time.Date(s.today.Year(), s.today.Month(), 1, 0, 0, 0, 0, s.today.Location())
+		return s.setDate(first.AddDate(0, step, 0), "month")
+	case "year":
+		first := time.Date(s.today.Year(), time.January, 1, 0, 0, 0, 0, s.today.Location())
+		return s.setDate(first.AddDate(step, 0, 0), "year")
+	}
+	return ErrUnrecognized
+}
+
+// monthDate reads "june", "june 3" or "june 3 2025" starting at the month in
+// tokens[i]. When the day came before the month, as in "3 june", it is passed
+// in and the caller counts its token.
+func (p *Parser) monthDate(s *state, tokens []string, i, day int) (int, error) {
+	month := months[tokens[i]]
+	used := 1
+	if next := at(tokens, i+1); day == 0 && !yearPattern.MatchString(next) && at(tokens, i+2) != "am" && at(tokens, i+2) != "pm" {
+		if n, ok := number(next); ok && n >= 1 && n <= 31 {
+			day, used = n, 2
+		}
+	}
+	year := 0
+	if next := at(tokens, i+used); yearPattern.MatchString(next) {
+		year, _ = strconv.Atoi(next)
+		used++
+	}
+
+	if day == 0 {
+		if year == 0 {
+			year = p.pickYear(s, month, 0)
+		}
+		return used, s.setDate(time.Date(year, month, 1, 0, 0, 0, 0, s.today.Location()), "month")
+	}
+	return used, p.setDay(s, year, int(month), day)
+}
+
+// pickYear chooses the year for a date written without one: this year, or
+// the next (or, preferring the past, the previous) when that would put it on
+// the wrong side of today. day 0 stands for the whole month.
+func (p *Parser) pickYear(s *state, month time.Month, day int) int {
+	year := s.today.Year()
+	start := time.Date(year, month, max(day, 1), 0, 0, 0, 0, s.today.Location())
+	end := start.AddDate(0, 0, 1)
+	if day == 0 {
+		end = start.AddDate(0, 1, 0)
+	}
+	if p.Prefer == Past && start.After(s.today) {
+		return year - 1
+	}
+	if p.Prefer == Future && !end.After(s.today) {
+		return year + 1
+	}
+	return year
+}
+
+// setDay records a calendar day, rejecting ones that don't exist such as
+// 31 April. year 0 means the year wasn't given.
+func (p *Parser) setDay(s *state, year, month, day int) error {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return ErrUnrecognized
+	}
+	if year == 0 {
+		year = p.pickYear(s, time.Month(month), day)
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, s.today.Location())
+	if date.Day() != day {
+		return ErrUnrecognized
+	}
+	return s.setDate(date, "day")
+}
+
+// result turns what was read into a Result.
+func (p *Parser) result(s *state) (Result, error) {
+	if s.hasExact {
+		if s.hasDate || s.hasTime || s.dayPart != "" {
+			return Result{}, ErrUnrecognized
+		}
+		return Result{Start: s.exact, End: s.exact, HasTime: true}, nil
+	}
+
+	if s.dayPart != "" {
+		if !s.hasTime {
+			s.hour, s.minute, s.hasTime = dayParts[s.dayPart], 0, true
+		} else if s.dayPart != "morning" && s.hour < 12 {
+			s.hour += 12
+		}
+		if s.dayPart == "tonight" && !s.hasDate {
+			s.date, s.period, s.hasDate = s.today, "day", true
+		}
+	}
+	if !s.hasDate && !s.hasTime {
+		return Result{}, ErrUnrecognized
+	}
+
+	if !s.hasTime {
+		end := s.date.AddDate(0, 0, 1)
+		switch s.period {
+		case "week":
+			end = s.date.AddDate(0, 0, 7)
+		case "month":
+			end = s.date.AddDate(0, 1, 0)
+		case "year":
+			end = s.date.AddDate(1, 0, 0)
+		}
+		return Result{Start: s.date, End: end}, nil
+	}
+	if s.period != "" && s.period != "day" {
+		// "next week at 9" has no single day to put the time on.
+		return Result{}, ErrUnrecognized
+	}
+
+	date := s.date
+	if !s.hasDate {
+		date = s.today
+	}
+	t := time.Date(date.Year(), date.Month(), date.Day(), s.hour, s.minute, 0, 0, date.Location())
+	if !s.hasDate {
+		if p.Prefer == Future && !t.After(s.ref) {
+			t = t.AddDate(0, 0, 1)
+		}
+		if p.Prefer == Past && t.After(s.ref) {
+			t = t.AddDate(0, 0, -1)
+		}
+	}
+	return Result{Start: t, End: t, HasTime: true}, nil
+}
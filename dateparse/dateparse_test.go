@@ -0,0 +1,157 @@
+package dateparse
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// zone is fixed so the tests don't depend on the machine's time zone data.
+var zone = time.FixedZone("UTC-5", -5*60*60)
+
+// ref is Wednesday 18 March 2026, 14:30.
+var ref = time.Date(2026, time.March, 18, 14, 30, 0, 0, zone)
+
+func day(year int, month time.Month, d int) Result {
+	start := time.Date(year, month, d, 0, 0, 0, 0, zone)
+	return Result{Start: start, End: start.AddDate(0, 0, 1)}
+}
+
+func span(start Result, days int) Result {
+	return Result{Start: start.Start, End: start.Start.AddDate(0, 0, days)}
+}
+
+func moment(year int, month time.Month, d, hour, minute int) Result {
+	t := time.Date(year, month, d, hour, minute, 0, 0, zone)
+	return Result{Start: t, End: t, HasTime: true}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		locale string
+		prefer Direction
+		text   string
+		want   Result
+	}{
+		// Relative moments.
+		{"en", Future, "in 2 hours", moment(2026, time.March, 18, 16, 30)},
+		{"en", Future, "in 45 minutes", moment(2026, time.March, 18, 15, 15)},
+		{"en", Future, "in an hour", moment(2026, time.March, 18, 15, 30)},
+		{"en", Past, "2 hours ago", moment(2026, time.March, 18, 12, 30)},
+
+		// Days and times, future.
+		{"en", Future, "next friday at 6pm", moment(2026, time.March, 20, 18, 0)},
+		{"en", Future, "Next Friday at 6 p.m.", moment(2026, time.March, 20, 18, 0)},
+		{"en", Future, "next wednesday", day(2026, time.March, 25)},
+		{"en", Future, "friday", day(2026, time.March, 20)},
+		{"en", Future, "tomorrow 9am", moment(2026, time.March, 19, 9, 0)},
+		{"en", Future, "tomorrow morning", moment(2026, time.March, 19, 9, 0)},
+		{"en", Future, "tonight", moment(2026, time.March, 18, 20, 0)},
+		{"en", Future, "9am", moment(2026, time.March, 19, 9, 0)},
+		{"en", Future, "at 15:45", moment(2026, time.March, 18, 15, 45)},
+		{"en", Future, "day after tomorrow", day(2026, time.March, 20)},
+		{"en", Future, "in 3 days", day(2026, time.March, 21)},
+		{"en", Future, "june 3", day(2026, time.June, 3)},
+		{"en", Future, "3rd of june at noon", moment(2026, time.June, 3, 12, 0)},
+		{"en", Future, "3/4", day(2027, time.March, 4)},
+		{"en", Future, "2026-02-28", day(2026, time.February, 28)},
+		{"en", Future, "next month", span(day(2026, time.April, 1), 30)},
+		{"en-GB", Future, "3/4", day(2026, time.April, 3)},
+
+		// Past.
+		{"en", Past, "yesterday", day(2026, time.March, 17)},
+		{"en", Past, "3 days ago", day(2026, time.March, 15)},
+		{"en", Past, "friday", day(2026, time.March, 13)},
+		{"en", Past, "last friday", day(2026, time.March, 13)},
+		{"en", Past, "9am", moment(2026, time.March, 18, 9, 0)},
+		{"en", Past, "june 3", day(2025, time.June, 3)},
+		{"en", Past, "last week", span(day(2026, time.March, 8), 7)},
+		{"en-GB", Past, "last week", span(day(2026, time.March, 9), 7)},
+		{"en", Past, "this month", span(day(2026, time.March, 1), 31)},
+		{"en", Past, "last year", span(day(2025, time.January, 1), 365)},
+
+		// Spanish.
+		{"es", Future, "mañana a las 9", moment(2026, time.March, 19, 9, 0)},
+		{"es", Future, "el próximo viernes a las 6 de la tarde", moment(2026, time.March, 20, 18, 0)},
+		{"es", Future, "en 2 horas", moment(2026, time.March, 18, 16, 30)},
+		{"es", Future, "pasado mañana", day(2026, time.March, 20)},
+		{"es", Future, "esta noche", moment(2026, time.March, 18, 20, 0)},
+		{"es", Future, "el viernes que viene", day(2026, time.March, 20)},
+		{"es", Future, "3 de junio", day(2026, time.June, 3)},
+		{"es", Future, "3/4", day(2026, time.April, 3)},
+		{"es", Past, "ayer", day(2026, time.March, 17)},
+		{"es", Past, "hace 3 días", day(2026, time.March, 15)},
+		{"es", Past, "la semana pasada", span(day(2026, time.March, 9), 7)},
+		{"es_MX", Past, "anteayer", day(2026, time.March, 16)},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.locale, zone, tt.prefer).Parse(tt.text, ref)
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.locale, tt.text, err)
+			continue
+		}
+		if !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) || got.HasTime != tt.want.HasTime {
+			t.Errorf("%s %q = %v to %v (time %t), want %v to %v (time %t)", tt.locale, tt.text,
+				got.Start, got.End, got.HasTime, tt.want.Start, tt.want.End, tt.want.HasTime)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		locale string
+		text   string
+	}{
+		{"en", ""},
+		{"en", "banana"},
+		{"en", "13pm"},
+		{"en", "0am"},
+		{"en", "25:00"},
+		{"en", "10:60"},
+		{"en", "2026-02-30"},
+		{"en", "2026-13-01"},
+		{"en", "april 31"},
+		{"en-GB", "31/4"},
+		{"en", "in hours"},
+		{"en", "in 2 hours tomorrow"},
+		{"en", "tomorrow yesterday"},
+		{"en", "next week at 9am"},
+		{"en", "9"},
+		{"es", "30 de febrero"},
+		{"es", "mañana a las 13pm"},
+	}
+	for _, tt := range tests {
+		for _, prefer := range []Direction{Future, Past} {
+			got, err := New(tt.locale, zone, prefer).Parse(tt.text, ref)
+			if !errors.Is(err, ErrUnrecognized) {
+				t.Errorf("%s %q = %v, %v; want ErrUnrecognized", tt.locale, tt.text, got, err)
+			}
+		}
+	}
+}
+
+func TestParseUsesLocation(t *testing.T) {
+	// 02:00 UTC on the 19th is still the 18th five hours west.
+	utcRef := time.Date(2026, time.March, 19, 2, 0, 0, 0, time.UTC)
+	got, err := New("en", zone, Past).Parse("today", utcRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := day(2026, time.March, 18); !got.Start.Equal(want.Start) {
+		t.Errorf("today = %v, want %v", got.Start, want.Start)
+	}
+}
+
+func TestParseIsDeterministic(t *testing.T) {
+	parser := New("es", zone, Future)
+	first, err := parser.Parse("el próximo viernes a las 6 de la tarde", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		got, err := parser.Parse("el próximo viernes a las 6 de la tarde", ref)
+		if err != nil || got != first {
+			t.Fatalf("run %d = %v, %v; want %v", i, got, err, first)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package dateparse
+
+import (
+	"strings"
+	"time"
+)
+
+// Rules describe how one locale writes dates. Words maps the locale's words,
+// with accents removed, to the parser's canonical English tokens; words mapped
+// to "" are filler and dropped. Phrases does the same for sequences of words
+// and is applied first, longest phrase first.
+type Rules struct {
+	Words   map[string]string
+	Phrases map[string]string
+	Numbers map[string]int
+	// DayFirst reads 3/4 as 3 April rather than March 4.
+	DayFirst bool
+	// WeekStart is the first day of "this week", "next week" and so on.
+	WeekStart time.Weekday
+}
+
+// canonical tokens shared by every locale.
+var englishWords = map[string]string{
+	"today": "today", "tomorrow": "tomorrow", "tmrw": "tomorrow", "yesterday": "yesterday",
+	"next": "next", "coming": "next", "following": "next",
+	"last": "last", "previous": "last", "past": "last",
+	"this": "this", "in": "in", "ago": "ago", "at": "at",
+	"noon": "noon", "midday": "noon", "midnight": "midnight",
+	"morning": "morning", "afternoon": "afternoon", "evening": "evening", "tonight": "tonight", "night": "evening",
+	"am": "am", "a.m.": "am", "pm": "pm", "p.m.": "pm",
+	"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute",
+	"hour": "hour", "hours": "hour", "hr": "hour", "hrs": "hour",
+	"day": "day", "days": "day",
+	"week": "week", "weeks": "week", "wk": "week", "wks": "week",
+	"month": "month", "months": "month",
+	"year": "year", "years": "year", "yr": "year", "yrs": "year",
+	"monday": "monday", "mon": "monday",
+	"tuesday": "tuesday", "tue": "tuesday", "tues": "tuesday",
+	"wednesday": "wednesday", "wed": "wednesday",
+	"thursday": "thursday", "thu": "thursday", "thur": "thursday", "thurs": "thursday",
+	"friday": "friday", "fri": "friday",
+	"saturday": "saturday", "sat": "saturday",
+	"sunday": "sunday", "sun": "sunday",
+	"january": "january", "jan": "january", "february": "february", "feb": "february",
+	"march": "march", "mar": "march", "april": "april", "apr": "april", "may": "may",
+	"june": "june", "jun": "june", "july": "july", "jul": "july",
+	"august": "august", "aug": "august", "september": "september", "sep": "september", "sept": "september",
+	"october": "october", "oct": "october", "november": "november", "nov": "november",
+	"december": "december", "dec": "december",
+	"on": "", "the": "", "of": "", "from": "", "since": "", "by": "", "o'clock": "", "oclock": "",
+}
+
+var englishPhrases = map[string]string{
+	"day after tomorrow":   "overmorrow",
+	"day before yesterday": "ereyesterday",
+	"in the morning":       "morning",
+	"in the afternoon":     "afternoon",
+	"in the evening":       "evening",
+	"at night":             "evening",
+}
+
+var englishNumbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+var spanishWords = map[string]string{
+	"hoy": "today", "manana": "tomorrow", "ayer": "yesterday", "anteayer": "ereyesterday",
+	"proximo": "next", "proxima": "next", "siguiente": "next",
+	"pasado": "last", "pasada": "last", "anterior": "last", "ultimo": "last", "ultima": "last",
+	"este": "this", "esta": "this", "en": "in", "hace": "ago",
+	"mediodia": "noon", "medianoche": "midnight",
+	"tarde": "afternoon", "noche": "evening",
+	"minuto": "minute", "minutos": "minute", "hora": "hour", "horas": "hour",
+	"dia": "day", "dias": "day", "semana": "week", "semanas": "week",
+	"mes": "month", "meses": "month", "ano": "year", "anos": "year",
+	"lunes": "monday", "martes": "tuesday", "miercoles": "wednesday", "jueves": "thursday",
+	"viernes": "friday", "sabado": "saturday", "domingo": "sunday",
+	"enero": "january", "febrero": "february", "marzo": "march", "abril": "april",
+	"mayo": "may", "junio": "june", "julio": "july", "agosto": "august",
+	"septiembre": "september", "setiembre": "september", "octubre": "october",
+	"noviembre": "november", "diciembre": "december",
+	"am": "am", "pm": "pm",
+	"el": "", "la": "", "los": "", "las": "", "de": "", "del": "", "desde": "", "para": "",
+}
+
+var spanishPhrases = map[string]string{
+	"pasado manana": "overmorrow",
+	"antes de ayer": "ereyesterday",
+	"que viene":     "next",
+	"a las":         "at",
+	"a la":          "at",
+	"por la manana": "morning",
+	"de la manana":  "morning",
+	"por la tarde":  "afternoon",
+	"de la tarde":   "afternoon",
+	"por la noche":  "evening",
+	"de la noche":   "evening",
+	"esta noche":    "tonight",
+	"esta manana":   "morning",
+}
+
+var spanishNumbers = map[string]int{
+	"un": 1, "una": 1, "uno": 1, "dos": 2, "tres": 3, "cuatro": 4, "cinco": 5, "seis": 6,
+	"siete": 7, "ocho": 8, "nueve": 9, "diez": 10, "once": 11, "doce": 12,
+}
+
+var locales = map[string]*Rules{
+	"en":    {Words: englishWords, Phrases: englishPhrases, Numbers: englishNumbers, WeekStart: time.Sunday},
+	"en-gb": {Words: englishWords, Phrases: englishPhrases, Numbers: englishNumbers, DayFirst: true, WeekStart: time.Monday},
+	"es":    {Words: spanishWords, Phrases: spanishPhrases, Numbers: spanishNumbers, DayFirst: true, WeekStart: time.Monday},
+}
+
+// Locale returns the rules for a locale such as "en_US", "en-GB" or "es",
+// falling back from the region to the language and then to English.
+func Locale(locale string) *Rules {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if rules, ok := locales[locale]; ok {
+		return rules
+	}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		if rules, ok := locales[language]; ok {
+			return rules
+		}
+	}
+	return locales["en"]
+}
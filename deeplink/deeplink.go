@@ -0,0 +1,79 @@
+// Package deeplink builds and reads the ref parameter of m.me links, which
+// Messenger hands back as a referral when someone follows the link. Refs are
+// signed so a link can't be edited into one the bot never made.
+package deeplink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Actions a link can start.
+const (
+	// OpenStorage opens the storage whose ID is the link's argument.
+	OpenStorage = "open"
+	// AcceptInvite redeems the share invite code in the argument.
+	AcceptInvite = "join"
+	// CreateStorage starts creating a storage, named by the argument if any.
+	CreateStorage = "new"
+)
+
+// sigLength is how many bytes of the HMAC a ref carries; Messenger limits
+// refs to 2083 characters, and short links are easier to share.
+const sigLength = 12
+
+var ErrInvalidRef = errors.New("invalid deep link ref")
+
+var encoding = base64.RawURLEncoding
+
+// Link is what following an m.me link asks the bot to do.
+type Link struct {
+	Action string
+	Arg    string
+}
+
+func valid(action string) bool {
+	return action == OpenStorage || action == AcceptInvite || action == CreateStorage
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ref:" + payload))
+	return encoding.EncodeToString(mac.Sum(nil)[:sigLength])
+}
+
+// Ref encodes and signs a link as "action.arg.signature". Only characters
+// Messenger allows in refs are used.
+func Ref(key []byte, link Link) (string, error) {
+	if !valid(link.Action) {
+		return "", ErrInvalidRef
+	}
+	payload := link.Action + "." + encoding.EncodeToString([]byte(link.Arg))
+	return payload + "." + sign(key, payload), nil
+}
+
+// Parse checks a ref's signature and decodes the link it holds.
+func Parse(key []byte, ref string) (Link, error) {
+	i := strings.LastIndexByte(ref, '.')
+	if i < 0 || !hmac.Equal([]byte(ref[i+1:]), []byte(sign(key, ref[:i]))) {
+		return Link{}, ErrInvalidRef
+	}
+	action, arg, found := strings.Cut(ref[:i], ".")
+	if !found || !valid(action) {
+		return Link{}, ErrInvalidRef
+	}
+	decoded, err := encoding.DecodeString(arg)
+	if err != nil {
+		return Link{}, ErrInvalidRef
+	}
+	return Link{Action: action, Arg: string(decoded)}, nil
+}
+
+// URL is the m.me link to the page that hands ref to the bot.
+func URL(pageUsername, ref string) string {
+	return "https://m.me/" + url.PathEscape(pageUsername) + "?ref=" + url.QueryEscape(ref)
+}
@@ -0,0 +1,98 @@
+package deeplink
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var key = []byte("test signing key")
+
+// refChars are the characters Messenger accepts in a ref.
+var refChars = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func TestRefRoundTrip(t *testing.T) {
+	links := []Link{
+		{Action: OpenStorage, Arg: "42"},
+		{Action: AcceptInvite, Arg: "K7Q2-XM9P"},
+		{Action: CreateStorage, Arg: ""},
+		{Action: CreateStorage, Arg: "Groceries"},
+		{Action: CreateStorage, Arg: "Trips. 2026 / Lisboa & Porto ✈"},
+	}
+	for _, link := range links {
+		ref, err := Ref(key, link)
+		if err != nil {
+			t.Errorf("Ref(%+v): %v", link, err)
+			continue
+		}
+		if !refChars.MatchString(ref) {
+			t.Errorf("Ref(%+v) = %q, which has characters Messenger doesn't allow", link, ref)
+		}
+		got, err := Parse(key, ref)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", ref, err)
+			continue
+		}
+		if got != link {
+			t.Errorf("Parse(Ref(%+v)) = %+v", link, got)
+		}
+	}
+}
+
+func TestRefRejectsUnknownAction(t *testing.T) {
+	if _, err := Ref(key, Link{Action: "delete", Arg: "42"}); !errors.Is(err, ErrInvalidRef) {
+		t.Fatalf("Ref with an unknown action = %v, want ErrInvalidRef", err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	ref, err := Ref(key, Link{Action: OpenStorage, Arg: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig := ref[:strings.LastIndexByte(ref, '.')], ref[strings.LastIndexByte(ref, '.')+1:]
+	otherArg, err := Ref(key, Link{Action: OpenStorage, Arg: "43"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := Ref([]byte("some other key"), Link{Action: OpenStorage, Arg: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A ref for an action the bot doesn't have, signed with the right key.
+	unknown := "delete." + encoding.EncodeToString([]byte("42"))
+	unknown += "." + sign(key, unknown)
+
+	flipped := []byte(sig)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+
+	tests := map[string]string{
+		"empty":              "",
+		"no signature":       payload,
+		"empty signature":    payload + ".",
+		"only a signature":   sig,
+		"tampered signature": payload + "." + string(flipped),
+		"short signature":    payload + "." + sig[:len(sig)-1],
+		"tampered action":    strings.Replace(ref, OpenStorage, AcceptInvite, 1),
+		"tampered argument":  otherArg[:strings.LastIndexByte(otherArg, '.')] + "." + sig,
+		"foreign key":        foreign,
+		"unknown action":     unknown,
+		"no argument":        OpenStorage + "." + sign(key, OpenStorage),
+	}
+	for name, ref := range tests {
+		if got, err := Parse(key, ref); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("%s: Parse(%q) = %+v, %v; want ErrInvalidRef", name, ref, got, err)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	if got, want := URL("quicky.bot", "open.NDI.abc-_"), "https://m.me/quicky.bot?ref=open.NDI.abc-_"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+// Package export renders a sender's storages as a file they can download:
+// JSON, CSV or Markdown, or a ZIP holding all three together with the files
+// attached to entries. The JSON layout is also what the importer reads back.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+// Formats an export can be rendered in.
+const (
+	JSON     = "json"
+	CSV      = "csv"
+	Markdown = "md"
+	ZIP      = "zip"
+)
+
+// Formats lists every format, in the order they are offered to users.
+var Formats = []string{JSON, CSV, Markdown, ZIP}
+
+// Valid reports whether format is one of Formats.
+func Valid(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Document is everything exported at once.
+type Document struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Storages   []Storage `json:"storages"`
+	// Location is the time zone timestamps are written in.
+	Location *time.Location `json:"-"`
+}
+
+// Storage is one storage with its entries. Folders lists the storages it is
+// nested in, outermost first.
+type Storage struct {
+	Name    string   `json:"name"`
+	Folders []string `json:"folders,omitempty"`
+	Mode    string   `json:"mode"`
+	Entries []Entry  `json:"entries"`
+}
+
+// Entry is one decrypted entry. Data holds the structured payload of typed
+// entries such as links and locations; Text is what the bot shows for it.
+type Entry struct {
+	ID        uint      `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Text      string    `json:"text"`
+	Data      string    `json:"data,omitempty"`
+	Done      bool      `json:"done,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	File      *File     `json:"file,omitempty"`
+}
+
+// File is an attachment of an entry. Data is only needed for ZIP exports,
+// where the file is stored under Path.
+type File struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size"`
+	Path     string `json:"path"`
+	Data     []byte `json:"-"`
+}
+
+// Render returns the document in format with the file name and MIME type to
+// send it under.
+func Render(doc Document, format string) (data []byte, fileName, mimeType string, err error) {
+	doc = prepare(doc)
+	base := "quickystorage-" + doc.ExportedAt.Format("2006-01-02")
+	switch format {
+	case JSON:
+		data, err = renderJSON(doc)
+		return data, base + ".json", "application/json", err
+	case CSV:
+		data, err = renderCSV(doc)
+		return data, base + ".csv", "text/csv", err
+	case Markdown:
+		return renderMarkdown(doc), base + ".md", "text/markdown", nil
+	case ZIP:
+		data, err = renderZIP(doc, base)
+		return data, base + ".zip", "application/zip", err
+	}
+	return nil, "", "", fmt.Errorf("unknown export format %q", format)
+}
+
+// prepare moves timestamps into the document's time zone and gives every
+// attached file its path in the ZIP.
+func prepare(doc Document) Document {
+	location := doc.Location
+	if location == nil {
+		location = time.UTC
+	}
+	doc.ExportedAt = doc.ExportedAt.In(location)
+	storages := make([]Storage, len(doc.Storages))
+	for i, storage := range doc.Storages {
+		entries := make([]Entry, len(storage.Entries))
+		for j, entry := range storage.Entries {
+			entry.Timestamp = entry.Timestamp.In(location)
+			if entry.File != nil {
+				file := *entry.File
+				file.Path = path.Join("attachments", strconv.FormatUint(uint64(entry.ID), 10)+"-"+safeName(file.Name))
+				entry.File = &file
+			}
+			entries[j] = entry
+		}
+		storage.Entries = entries
+		storages[i] = storage
+	}
+	doc.Storages = storages
+	return doc
+}
+
+// safeName keeps a file name usable inside a ZIP on any system.
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+func renderJSON(doc Document) ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func renderCSV(doc Document) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"storage", "folders", "timestamp", "type", "text", "done", "tags", "file"})
+	for _, storage := range doc.Storages {
+		for _, entry := range storage.Entries {
+			file := ""
+			if entry.File != nil {
+				file = entry.File.Path
+			}
+			w.Write([]string{
+				storage.Name,
+				strings.Join(storage.Folders, "/"),
+				entry.Timestamp.Format(time.RFC3339),
+				entry.Type,
+				entry.Text,
+				strconv.FormatBool(entry.Done),
+				hashtags(entry.Tags),
+				file,
+			})
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+func renderMarkdown(doc Document) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# QuickyStorage export\n\nExported %s.\n", doc.ExportedAt.Format("2006-01-02 15:04 MST"))
+	for _, storage := range doc.Storages {
+		fmt.Fprintf(&b, "\n## %s\n\n", strings.Join(append(append([]string(nil), storage.Folders...), storage.Name), " / "))
+		if len(storage.Entries) == 0 {
+			b.WriteString("_Empty._\n")
+		}
+		for _, entry := range storage.Entries {
+			prefix := "- "
+			if storage.Mode == models.StorageModeChecklist {
+				prefix = "- [ ] "
+				if entry.Done {
+					prefix = "- [x] "
+				}
+			}
+			text := strings.ReplaceAll(entry.Text, "\n", "\n  ")
+			fmt.Fprintf(&b, "%s**%s** %s", prefix, entry.Timestamp.Format("2006-01-02 15:04"), text)
+			if entry.File != nil {
+				fmt.Fprintf(&b, " ([%s](%s))", entry.File.Name, entry.File.Path)
+			}
+			if len(entry.Tags) > 0 {
+				b.WriteString(" " + hashtags(entry.Tags))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+type zipFile struct {
+	name string
+	data []byte
+}
+
+// renderZIP bundles the JSON, CSV and Markdown renderings with every
+// attached file.
+func renderZIP(doc Document, base string) ([]byte, error) {
+	jsonData, err := renderJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	csvData, err := renderCSV(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	files := []zipFile{
+		{base + ".json", jsonData},
+		{base + ".csv", csvData},
+		{base + ".md", renderMarkdown(doc)},
+	}
+	for _, storage := range doc.Storages {
+		for _, entry := range storage.Entries {
+			if entry.File != nil && entry.File.Data != nil {
+				files = append(files, zipFile{entry.File.Path, entry.File.Data})
+			}
+		}
+	}
+	for _, file := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: doc.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func hashtags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(tags, " #")
+}
@@ -4,6 +4,8 @@ go 1.24.0
 
 require (
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.36.0
+	golang.org/x/net v0.38.0
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.25.12
 )
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+)
+
+// attachmentTypes lists the attachment types that can be stored. Each has a
+// "content.<type>" message naming it in place of an entry's text.
+var attachmentTypes = map[string]bool{
+	"image": true,
+	"file":  true,
+	"audio": true,
+	"video": true,
+}
+
+// handleAttachmentInput stores the attachments sent while in the
+// "waiting_for_data" state as entries of storageName: files go to the blob
+// store, locations and shared links become typed entries.
+func handleAttachmentInput(senderID, storageName string, attachments []models.MessageAttachment) error {
+	stored := 0
+	for _, messageAttachment := range attachments {
+		contentType, data, typed, err := typedAttachment(messageAttachment)
+		if err != nil {
+			return err
+		}
+		if typed {
+			err := database.StoreTypedDataInDB(senderID, storageName, time.Now(), contentType, data)
+			if errors.Is(err, database.ErrReadOnly) {
+				return sendReadOnly(senderID)
+			}
+			if err != nil {
+				log.Printf("Failed to store %s in database: %v", contentType, err)
+				sendText(senderID, tr(senderID, "attachment.store_failed", "type", contentType))
+				continue
+			}
+			notifyEntryAdded(senderID, storageName, contentType, data)
+			stored++
+			continue
+		}
+
+		if !attachmentTypes[messageAttachment.Type] {
+			log.Printf("Unsupported attachment type: %s", messageAttachment.Type)
+			sendText(senderID, tr(senderID, "attachment.unsupported", "type", messageAttachment.Type))
+			continue
+		}
+
+		fileData, mimeType, fileName, err := services.DownloadAttachment(messageAttachment.Payload.URL)
+		if err != nil {
+			log.Printf("Failed to download attachment for senderID %s: %v", senderID, err)
+			sendText(senderID, tr(senderID, "attachment.download_failed", "type", messageAttachment.Type))
+			continue
+		}
+
+		attachment := models.Attachment{
+			Type:     messageAttachment.Type,
+			FileName: fileName,
+			MimeType: mimeType,
+		}
+		err = database.StoreAttachmentInDB(senderID, storageName, time.Now(), attachment, fileData)
+		if errors.Is(err, database.ErrReadOnly) {
+			return sendReadOnly(senderID)
+		}
+		if err != nil {
+			log.Printf("Failed to store attachment in database: %v", err)
+			sendText(senderID, tr(senderID, "attachment.store_failed", "type", messageAttachment.Type))
+			continue
+		}
+		if payload, err := models.EncodePayload(attachment.Payload()); err == nil {
+			notifyEntryAdded(senderID, storageName, models.ContentTypeMedia, payload)
+		}
+		stored++
+	}
+
+	if stored == 0 {
+		return nil
+	}
+	userState[senderID] = "storing_data"
+	if err := sendText(senderID, trn(senderID, "attachment.stored", stored, "storage", storageName)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
+// sendAttachment decrypts a stored file and sends it back through the Send API.
+func sendAttachment(senderID string, attachment models.Attachment) error {
+	data, err := database.GetAttachmentData(attachment)
+	if err != nil {
+		return err
+	}
+	attachmentID, err := services.UploadAttachment(attachment.Type, attachment.FileName, attachment.MimeType, data)
+	if err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, services.AttachmentMessage(senderID, attachment.Type, attachmentID))
+}
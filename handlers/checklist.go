@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	toggleItemPrefix = "TOGGLE_ITEM_"
+	// checklistItemReplies leaves room for the two checklist actions among
+	// Messenger's 13 quick replies.
+	checklistItemReplies = 11
+)
+
+func checkBox(done bool) string {
+	if done {
+		return "☑"
+	}
+	return "☐"
+}
+
+// sendChecklist shows a checklist storage as a numbered list. Items can be
+// ticked off from the quick replies or by sending their number, and any other
+// text is added as a new item. A locked checklist asks for its PIN instead.
+func sendChecklist(senderID string, index int) error {
+	storage := userStorage[senderID][index]
+	if isLocked(senderID, storage.ID) {
+		return askPIN(senderID, storage.ID, pinActionOpen)
+	}
+	contents, err := database.GetStorageData(senderID, storage.StorageName)
+	if err != nil {
+		return err
+	}
+	getSession(senderID).storageIndex = index
+	userState[senderID] = "checklist"
+
+	if len(contents) == 0 {
+		return sendText(senderID, tr(senderID, "checklist.empty", "storage", storage.StorageName))
+	}
+
+	var b strings.Builder
+	done := 0
+	replies := []map[string]string{}
+	for i, content := range contents {
+		fmt.Fprintf(&b, "%d. %s %s\n", i+1, checkBox(content.Done), content.Text(userLocale(senderID)))
+		if content.Done {
+			done++
+		}
+		if i < checklistItemReplies {
+			replies = append(replies, map[string]string{"title": checkBox(content.Done) + " " + content.Text(userLocale(senderID)), "payload": fmt.Sprintf("%s%d", toggleItemPrefix, content.ID)})
+		}
+	}
+	heading := trn(senderID, "checklist.heading", len(contents), "storage", storage.StorageName, "done", done) + "\n\n"
+	if err := sendText(senderID, heading+b.String()); err != nil {
+		return err
+	}
+
+	replies = append(replies,
+		map[string]string{"title": tr(senderID, "checklist.clear_completed"), "payload": fmt.Sprintf("CLEAR_COMPLETED_%d", storage.ID)},
+		map[string]string{"title": tr(senderID, "checklist.uncheck_all"), "payload": fmt.Sprintf("UNCHECK_ALL_%d", storage.ID)},
+	)
+	text := tr(senderID, "checklist.prompt")
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, replies))
+}
+
+// sendChecklistByID shows a checklist again after a change to it, which
+// needn't be the one the user has open any more.
+func sendChecklistByID(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		userState[senderID] = "waiting_for_action"
+		return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+	}
+	return sendChecklist(senderID, index)
+}
+
+func handleToggleItem(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return askPIN(senderID, content.StorageContentID, pinActionOpen)
+	}
+	if _, err := database.ToggleContentDone(senderID, contentID); errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	} else if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	} else if err != nil {
+		return err
+	}
+	return sendChecklistByID(senderID, content.StorageContentID)
+}
+
+func handleClearCompleted(senderID string, storageID uint) error {
+	if isLocked(senderID, storageID) {
+		return askPIN(senderID, storageID, pinActionOpen)
+	}
+	cleared, err := database.ClearCompleted(senderID, storageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := sendText(senderID, trn(senderID, "checklist.cleared", int(cleared))); err != nil {
+		return err
+	}
+	return sendChecklistByID(senderID, storageID)
+}
+
+func handleUncheckAll(senderID string, storageID uint) error {
+	if isLocked(senderID, storageID) {
+		return askPIN(senderID, storageID, pinActionOpen)
+	}
+	if _, err := database.UncheckAll(senderID, storageID); errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	} else if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	} else if err != nil {
+		return err
+	}
+	return sendChecklistByID(senderID, storageID)
+}
+
+// handleChecklistInput handles text sent while a checklist is open: a number
+// ticks that item, anything else becomes a new item.
+func handleChecklistInput(senderID, text string) error {
+	index := getSession(senderID).storageIndex
+	if index >= len(userStorage[senderID]) {
+		userState[senderID] = "waiting_for_action"
+		return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+	}
+	if storageID := userStorage[senderID][index].ID; isLocked(senderID, storageID) {
+		return askPIN(senderID, storageID, pinActionOpen)
+	}
+	storageName := userStorage[senderID][index].StorageName
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return sendText(senderID, tr(senderID, "checklist.send_item"))
+	}
+
+	if n, err := strconv.Atoi(text); err == nil {
+		contents, err := database.GetStorageData(senderID, storageName)
+		if err != nil {
+			return err
+		}
+		if n < 1 || n > len(contents) {
+			return sendText(senderID, tr(senderID, "checklist.no_item", "number", n))
+		}
+		return handleToggleItem(senderID, contents[n-1].ID)
+	}
+
+	contentType, data, err := classifyText(text)
+	if err != nil {
+		return err
+	}
+	err = database.StoreTypedDataInDB(senderID, storageName, time.Now(), contentType, data, utils.ParseHashtags(text)...)
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "checklist.add_failed"))
+		return err
+	}
+	notifyEntryAdded(senderID, storageName, contentType, data)
+	return sendChecklist(senderID, index)
+}
+
+// handleToggleStorageMode turns a storage into a checklist, or back into
+// plain notes.
+func handleToggleStorageMode(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if isLocked(senderID, storageID) {
+		return sendStorageLocked(senderID, storageID)
+	}
+	mode := models.StorageModeChecklist
+	if userStorage[senderID][index].Mode == models.StorageModeChecklist {
+		mode = models.StorageModeNotes
+	}
+	if err := database.SetStorageMode(senderID, storageID, mode); err != nil {
+		sendText(senderID, tr(senderID, "storage.change_failed"))
+		return err
+	}
+	userStorage[senderID][index].Mode = mode
+	refreshSharedStorage(userStorage[senderID][index])
+
+	if mode == models.StorageModeChecklist {
+		return sendChecklist(senderID, index)
+	}
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "checklist.now_plain", "storage", userStorage[senderID][index].StorageName)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/dateparse"
+	"github.com/markDoesany/quickymessenger/export"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/utils"
+)
+
+const commandPrefix = "/"
+
+type command struct {
+	name    string
+	usage   string
+	minArgs int
+	run     func(senderID string, args []string) error
+}
+
+// commands lists every command. Each one's summary in the help text is the
+// "command.<name>" message.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "new", usage: "<storage>", minArgs: 1, run: cmdNew},
+		{name: "add", usage: "<storage> <text>", minArgs: 2, run: cmdAdd},
+		{name: "show", usage: "<storage> [when]", minArgs: 1, run: cmdShow},
+		{name: "find", usage: "<text>", minArgs: 1, run: cmdFind},
+		{name: "rm", usage: "<storage>", minArgs: 1, run: cmdRemove},
+		{name: "rename", usage: "<storage> <new name>", minArgs: 2, run: cmdRename},
+		{name: "move", usage: "<storage> [folder|/]", minArgs: 1, run: cmdMove},
+		{name: "merge", usage: "<storage> <into>", minArgs: 2, run: cmdMerge},
+		{name: "remind", usage: "<storage> <when>", minArgs: 2, run: cmdRemind},
+		{name: "reminders", run: cmdReminders},
+		{name: "list", run: cmdList},
+		{name: "export", usage: "[storage] [json|csv|md|zip]", run: cmdExport},
+		{name: "import", usage: "[storage]", run: cmdImport},
+		{name: "share", usage: "<storage>", minArgs: 1, run: cmdShare},
+		{name: "join", usage: "<code>", minArgs: 1, run: cmdJoin},
+		{name: "link", usage: "<storage>", minArgs: 1, run: cmdLink},
+		{name: "mydata", run: cmdMyData},
+		{name: "tags", usage: "[tag]", run: cmdTags},
+		{name: "settings", run: cmdSettings},
+		{name: "set", usage: "<timezone|clock|date|language> <value>", minArgs: 2, run: cmdSet},
+		{name: "view", usage: "<digest|full>", minArgs: 1, run: cmdView},
+		{name: "help", usage: "[topic]", run: cmdHelp},
+	}
+}
+
+func isCommand(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), commandPrefix)
+}
+
+// handleCommand runs a slash command such as `/add groceries milk`.
+// Commands work from any state and leave the user waiting for an action.
+func handleCommand(senderID, text string) {
+	args, err := splitArgs(strings.TrimPrefix(strings.TrimSpace(text), commandPrefix))
+	if err != nil {
+		sendText(senderID, tr(senderID, "command.unreadable", "error", err.Error()))
+		return
+	}
+	if len(args) == 0 {
+		sendText(senderID, helpText(userLocale(senderID)))
+		return
+	}
+
+	name := strings.ToLower(args[0])
+	args = args[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if len(args) < cmd.minArgs {
+			sendText(senderID, tr(senderID, "command.usage", "usage", cmd.signature()))
+			return
+		}
+		userState[senderID] = "waiting_for_action"
+		if err := cmd.run(senderID, args); err != nil {
+			log.Printf("Command /%s failed for senderID %s: %v", cmd.name, senderID, err)
+		}
+		return
+	}
+	sendText(senderID, tr(senderID, "command.unknown", "command", commandPrefix+name))
+}
+
+func (c command) signature() string {
+	if c.usage == "" {
+		return commandPrefix + c.name
+	}
+	return commandPrefix + c.name + " " + c.usage
+}
+
+func helpText(locale string) string {
+	var b strings.Builder
+	b.WriteString(i18n.T(locale, "command.heading") + "\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "%s - %s\n", cmd.signature(), i18n.T(locale, "command."+cmd.name))
+	}
+	b.WriteString("\n" + i18n.T(locale, "command.quotes"))
+	return b.String()
+}
+
+// splitArgs splits a command line on whitespace, keeping single- or
+// double-quoted sections together. A backslash escapes the next character.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("missing closing quote")
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// sendText sends text, split into several messages when it is longer than
+// Messenger allows.
+func sendText(senderID, text string) error {
+	for _, chunk := range utils.SplitMessage(text, utils.MaxMessageLength) {
+		if err := services.SendMessage(senderID, services.TextMessage(senderID, chunk)); err != nil {
+			log.Printf("Failed to send message: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// findStorageIndex returns the position of the named storage in the user's
+// cached storages, or -1 when there is none.
+func findStorageIndex(senderID, storageName string) int {
+	for i, storage := range userStorage[senderID] {
+		if strings.EqualFold(storage.StorageName, storageName) {
+			return i
+		}
+	}
+	return -1
+}
+
+func cmdNew(senderID string, args []string) error {
+	storageName := strings.Join(args, " ")
+	if err := createStorage(senderID, storageName); err != nil {
+		if errors.Is(err, database.ErrStorageExists) {
+			return sendText(senderID, tr(senderID, "storage.exists", "storage", storageName))
+		}
+		sendText(senderID, tr(senderID, "storage.create_failed"))
+		return err
+	}
+	getSession(senderID).storageIndex = len(userStorage[senderID]) - 1
+	return sendText(senderID, tr(senderID, "storage.created", "storage", storageName))
+}
+
+func cmdAdd(senderID string, args []string) error {
+	index := findStorageIndex(senderID, args[0])
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing_create", "storage", args[0]))
+	}
+	storageName := userStorage[senderID][index].StorageName
+	text := strings.Join(args[1:], " ")
+	contentType, data, err := classifyText(text)
+	if err != nil {
+		return err
+	}
+	err = database.StoreTypedDataInDB(senderID, storageName, time.Now(), contentType, data, utils.ParseHashtags(text)...)
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "data.store_failed"))
+		return err
+	}
+	notifyEntryAdded(senderID, storageName, contentType, data)
+	getSession(senderID).storageIndex = index
+	return sendText(senderID, tr(senderID, "data.stored_in", "storage", storageName, "data", models.DataText(userLocale(senderID), contentType, data)))
+}
+
+// cmdShow opens a storage, or with a time such as `/show groceries last week`
+// lists the entries stored then.
+func cmdShow(senderID string, args []string) error {
+	storageName := strings.Join(args, " ")
+	if index := findStorageIndex(senderID, storageName); index >= 0 {
+		return handleStorageSelection(senderID, index+1)
+	}
+
+	parser := userDateParser(senderID, dateparse.Past)
+	for split := len(args) - 1; split > 0; split-- {
+		index := findStorageIndex(senderID, strings.Join(args[:split], " "))
+		if index < 0 {
+			continue
+		}
+		if isLocked(senderID, userStorage[senderID][index].ID) {
+			return sendStorageLocked(senderID, userStorage[senderID][index].ID)
+		}
+		when := strings.Join(args[split:], " ")
+		result, err := parser.Parse(when, time.Now())
+		if err != nil {
+			return sendText(senderID, tr(senderID, "show.bad_time", "when", when, "storage", userStorage[senderID][index].StorageName))
+		}
+		return showStorageBetween(senderID, userStorage[senderID][index].StorageName, when, result)
+	}
+	return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+}
+
+// showStorageBetween lists the entries of a storage stored in the period of
+// result. A moment such as "2 hours ago" stands for its whole day.
+func showStorageBetween(senderID, storageName, when string, result dateparse.Result) error {
+	start, end := result.Start, result.End
+	if result.HasTime {
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+		end = start.AddDate(0, 0, 1)
+	}
+	contents, err := database.GetStorageDataBetween(senderID, storageName, start, end)
+	if err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		return sendText(senderID, tr(senderID, "show.nothing", "storage", storageName, "when", when))
+	}
+
+	results := make([]database.SearchResult, 0, len(contents))
+	for _, content := range contents {
+		results = append(results, database.SearchResult{StorageName: storageName, Content: content})
+	}
+	return sendText(senderID, formatResults(trn(senderID, "show.heading", len(contents), "when", when), results, userTimeFormat(senderID)))
+}
+
+func cmdFind(senderID string, args []string) error {
+	query := strings.Join(args, " ")
+	results, err := database.SearchData(senderID, userLocale(senderID), query)
+	if err != nil {
+		sendText(senderID, tr(senderID, "find.failed"))
+		return err
+	}
+	results, hidden := withoutLocked(senderID, results)
+	if len(results) == 0 {
+		return sendText(senderID, strings.TrimSpace(tr(senderID, "find.nothing", "query", query)+"\n"+hidden))
+	}
+
+	text := formatResults(trn(senderID, "find.heading", len(results), "query", query), results, userTimeFormat(senderID))
+	if hidden != "" {
+		text += "\n" + hidden
+	}
+	return sendText(senderID, text)
+}
+
+// formatResults lists entries from several storages under a heading.
+func formatResults(heading string, results []database.SearchResult, format utils.TimeFormat) string {
+	var b strings.Builder
+	b.WriteString(heading + "\n")
+	for _, result := range results {
+		fmt.Fprintf(&b, "\n[%s] %s\n%s\n", result.StorageName, format.Timestamp(result.Content.Timestamp), result.Content.Text(format.Language))
+	}
+	return b.String()
+}
+
+func cmdRemove(senderID string, args []string) error {
+	storageName := strings.Join(args, " ")
+	index := findStorageIndex(senderID, storageName)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+	}
+	return confirmRemoveStorage(senderID, userStorage[senderID][index].ID)
+}
+
+func cmdList(senderID string, args []string) error {
+	if len(userStorage[senderID]) == 0 {
+		return sendText(senderID, tr(senderID, "list.empty"))
+	}
+	var b strings.Builder
+	b.WriteString(tr(senderID, "list.heading"))
+	writeStorageTree(&b, senderID, 0, 0)
+	return sendText(senderID, b.String())
+}
+
+// writeStorageTree lists the storages in a folder, indenting nested ones.
+func writeStorageTree(b *strings.Builder, senderID string, folderID uint, depth int) {
+	if depth > len(userStorage[senderID]) {
+		return
+	}
+	for _, index := range childIndexes(senderID, folderID) {
+		storage := userStorage[senderID][index]
+		fmt.Fprintf(b, "\n%s- %s", strings.Repeat("  ", depth), storage.StorageName)
+		writeStorageTree(b, senderID, storage.ID, depth+1)
+	}
+}
+
+func cmdMove(senderID string, args []string) error {
+	index := findStorageIndex(senderID, args[0])
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", args[0]))
+	}
+	if len(args) == 1 {
+		return handleMoveStorage(senderID, userStorage[senderID][index].ID)
+	}
+
+	folderID := uint(0)
+	if folderName := strings.Join(args[1:], " "); folderName != "/" {
+		folderIndex := findStorageIndex(senderID, folderName)
+		if folderIndex < 0 {
+			return sendText(senderID, tr(senderID, "storage.missing", "storage", folderName))
+		}
+		folderID = userStorage[senderID][folderIndex].ID
+	}
+	return handleMoveStorageTo(senderID, userStorage[senderID][index].ID, folderID)
+}
+
+func cmdHelp(senderID string, args []string) error {
+	if len(args) > 0 {
+		return handleHelpTopic(senderID, strings.ToLower(strings.Join(args, "_")))
+	}
+	return sendText(senderID, helpText(userLocale(senderID)))
+}
+
+func cmdView(senderID string, args []string) error {
+	mode := strings.ToLower(args[0])
+	if mode != models.ViewModeDigest && mode != models.ViewModeFull {
+		return sendText(senderID, tr(senderID, "view.usage"))
+	}
+
+	preference, err := database.GetUserPreference(senderID)
+	if err != nil {
+		return err
+	}
+	preference.ViewMode = mode
+	if err := database.SaveUserPreference(preference); err != nil {
+		sendText(senderID, tr(senderID, "settings.save_failed"))
+		return err
+	}
+	forgetPreferences(senderID)
+	return sendText(senderID, tr(senderID, "view.changed", "mode", mode))
+}
+
+func cmdTags(senderID string, args []string) error {
+	if len(args) > 0 {
+		return handleTagFilter(senderID, args[0])
+	}
+	return handleBrowseTags(senderID)
+}
+
+func cmdMerge(senderID string, args []string) error {
+	sourceIndex := findStorageIndex(senderID, args[0])
+	if sourceIndex < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", args[0]))
+	}
+	targetName := strings.Join(args[1:], " ")
+	targetIndex := findStorageIndex(senderID, targetName)
+	if targetIndex < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", targetName))
+	}
+	if sourceIndex == targetIndex {
+		return sendText(senderID, tr(senderID, "merge.same"))
+	}
+	return handleMergeStorageInto(senderID, userStorage[senderID][sourceIndex].ID, userStorage[senderID][targetIndex].ID)
+}
+
+func cmdRename(senderID string, args []string) error {
+	index := findStorageIndex(senderID, args[0])
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", args[0]))
+	}
+	return renameStorageTo(senderID, userStorage[senderID][index].ID, strings.Join(args[1:], " "))
+}
+
+func cmdRemind(senderID string, args []string) error {
+	index := findStorageIndex(senderID, args[0])
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", args[0]))
+	}
+	return scheduleReminder(senderID, userStorage[senderID][index].ID, 0, strings.Join(args[1:], " "))
+}
+
+func cmdReminders(senderID string, args []string) error {
+	reminders, err := database.GetReminders(senderID)
+	if err != nil {
+		return err
+	}
+	if len(reminders) == 0 {
+		return sendText(senderID, tr(senderID, "reminders.none"))
+	}
+	return sendText(senderID, formatReminders(senderID, reminders))
+}
+
+func cmdSettings(senderID string, args []string) error {
+	preference, err := database.GetUserPreference(senderID)
+	if err != nil {
+		return err
+	}
+	return sendText(senderID, formatPreferences(preference))
+}
+
+func cmdSet(senderID string, args []string) error {
+	preference, err := database.GetUserPreference(senderID)
+	if err != nil {
+		return err
+	}
+	if reply := setPreference(userLocale(senderID), &preference, args[0], strings.Join(args[1:], " ")); reply != "" {
+		return sendText(senderID, reply)
+	}
+	if err := database.SaveUserPreference(preference); err != nil {
+		sendText(senderID, tr(senderID, "settings.save_failed"))
+		return err
+	}
+	forgetPreferences(senderID)
+	return sendText(senderID, formatPreferences(preference))
+}
+
+// cmdExport exports a storage, or everything without one. Without a format it
+// asks for one.
+func cmdExport(senderID string, args []string) error {
+	format := ""
+	if len(args) > 0 && export.Valid(strings.ToLower(args[len(args)-1])) {
+		format = strings.ToLower(args[len(args)-1])
+		args = args[:len(args)-1]
+	}
+
+	storageID := uint(0)
+	if len(args) > 0 {
+		storageName := strings.Join(args, " ")
+		index := findStorageIndex(senderID, storageName)
+		if index < 0 {
+			return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+		}
+		storageID = userStorage[senderID][index].ID
+	}
+	if format == "" {
+		return handleExport(senderID, storageID)
+	}
+	return startExport(senderID, storageID, format)
+}
+
+// cmdImport asks for a file to import into a storage, or into the storages
+// the file names without one.
+func cmdImport(senderID string, args []string) error {
+	if len(args) == 0 {
+		return handleImport(senderID, 0)
+	}
+	storageName := strings.Join(args, " ")
+	index := findStorageIndex(senderID, storageName)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+	}
+	return handleImport(senderID, userStorage[senderID][index].ID)
+}
+
+func cmdShare(senderID string, args []string) error {
+	storageName := strings.Join(args, " ")
+	index := findStorageIndex(senderID, storageName)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+	}
+	return handleShareStorage(senderID, userStorage[senderID][index].ID)
+}
+
+func cmdJoin(senderID string, args []string) error {
+	return handleJoin(senderID, args[0])
+}
+
+func cmdLink(senderID string, args []string) error {
+	storageName := strings.Join(args, " ")
+	index := findStorageIndex(senderID, storageName)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.missing", "storage", storageName))
+	}
+	return handleStorageLink(senderID, index)
+}
+
+func cmdMyData(senderID string, args []string) error {
+	return handleMyData(senderID)
+}
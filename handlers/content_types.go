@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+)
+
+const (
+	linkFetchTimeout = 10 * time.Second
+	// maxPrefetchedLinks caps how many pages one message makes the bot fetch.
+	maxPrefetchedLinks = 3
+)
+
+// linkFetcher looks up titles and descriptions of links users store.
+var linkFetcher = services.NewLinkFetcher(nil)
+
+// fetchedLinks holds the metadata prefetched for the message being handled,
+// by URL. It is only used under mu.
+var fetchedLinks map[string]services.LinkMetadata
+
+// classifyText works out what kind of entry a text message is and returns
+// the content type with the data to store for it.
+func classifyText(text string) (contentType, data string, err error) {
+	if link, ok := parseLink(text); ok {
+		data, err = models.EncodePayload(withLinkMetadata(link))
+		return models.ContentTypeLink, data, err
+	}
+	if contact, ok := parseVCard(text); ok {
+		data, err = models.EncodePayload(contact)
+		return models.ContentTypeContact, data, err
+	}
+	return models.ContentTypeText, text, nil
+}
+
+// parseLink recognizes a message that is nothing but a web address.
+func parseLink(text string) (models.LinkPayload, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, " \t\n") {
+		return models.LinkPayload{}, false
+	}
+	if strings.HasPrefix(strings.ToLower(text), "www.") {
+		text = "https://" + text
+	}
+
+	u, err := url.Parse(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Host, ".") {
+		return models.LinkPayload{}, false
+	}
+	return models.LinkPayload{URL: u.String()}, true
+}
+
+// prefetchLinkMetadata looks up the pages a message links to. Fetching can
+// take seconds, so it runs before mu is taken, and classifyText reads the
+// results from fetchedLinks.
+func prefetchLinkMetadata(text string) map[string]services.LinkMetadata {
+	links := map[string]services.LinkMetadata{}
+	ctx, cancel := context.WithTimeout(context.Background(), linkFetchTimeout)
+	defer cancel()
+
+	tried := 0
+	for _, field := range strings.Fields(text) {
+		link, ok := parseLink(field)
+		if !ok {
+			continue
+		}
+		if _, done := links[link.URL]; done {
+			continue
+		}
+		if tried == maxPrefetchedLinks {
+			break
+		}
+		tried++
+		metadata, err := linkFetcher.Fetch(ctx, link.URL)
+		if err != nil {
+			log.Printf("Failed to fetch link metadata for %s: %v", link.URL, err)
+			continue
+		}
+		links[link.URL] = metadata
+	}
+	return links
+}
+
+// withLinkMetadata fills in what the linked page says about itself, if it
+// was prefetched. Links are still stored when the page couldn't be fetched,
+// just without the extras.
+func withLinkMetadata(link models.LinkPayload) models.LinkPayload {
+	metadata, ok := fetchedLinks[link.URL]
+	if !ok {
+		return link
+	}
+	if metadata.Title != "" {
+		link.Title = metadata.Title
+	}
+	link.Description = metadata.Description
+	link.FaviconURL = metadata.FaviconURL
+	link.ImageURL = metadata.ImageURL
+	return link
+}
+
+// parseVCard reads the name, first phone number and first email address of a
+// vCard pasted as text.
+func parseVCard(text string) (models.ContactPayload, bool) {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(text)), "BEGIN:VCARD") {
+		return models.ContactPayload{}, false
+	}
+
+	var contact models.ContactPayload
+	for _, line := range strings.Split(text, "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		// Properties may carry parameters, e.g. TEL;TYPE=CELL:+63 912 345 6789.
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		value = strings.TrimSpace(value)
+		switch {
+		case name == "FN" && contact.Name == "":
+			contact.Name = value
+		case name == "N" && contact.Name == "":
+			parts := strings.Split(value, ";")
+			if len(parts) > 1 {
+				contact.Name = strings.TrimSpace(parts[1] + " " + parts[0])
+			} else {
+				contact.Name = value
+			}
+		case name == "TEL" && contact.Phone == "":
+			contact.Phone = strings.TrimPrefix(value, "tel:")
+		case name == "EMAIL" && contact.Email == "":
+			contact.Email = value
+		}
+	}
+	if contact.Name == "" && contact.Phone == "" && contact.Email == "" {
+		return models.ContactPayload{}, false
+	}
+	if contact.Name == "" {
+		contact.Name = "Unnamed contact"
+	}
+	return contact, true
+}
+
+// typedAttachment converts location and shared-link attachments into typed
+// entries. It reports false for attachments that carry a file instead.
+func typedAttachment(attachment models.MessageAttachment) (contentType, data string, ok bool, err error) {
+	switch {
+	case attachment.Type == "location" && attachment.Payload.Coordinates != nil:
+		data, err = models.EncodePayload(models.LocationPayload{
+			Latitude:  attachment.Payload.Coordinates.Lat,
+			Longitude: attachment.Payload.Coordinates.Long,
+			Title:     attachment.Title,
+		})
+		return models.ContentTypeLocation, data, true, err
+	case attachment.Type == "fallback" && attachment.Payload.URL != "":
+		data, err = models.EncodePayload(withLinkMetadata(models.LinkPayload{URL: attachment.Payload.URL, Title: attachment.Title}))
+		return models.ContentTypeLink, data, true, err
+	}
+	return "", "", false, nil
+}
+
+// sendTypedContent sends the type-specific part of an entry: a card with a
+// button to open a link or map, or to call a contact. Text entries have none.
+func sendTypedContent(senderID string, content models.Content) error {
+	var card templates.Card
+	switch content.Type {
+	case models.ContentTypeLink:
+		var link models.LinkPayload
+		if err := models.DecodePayload(content.Data, &link); err != nil {
+			return err
+		}
+		card = templates.Card{
+			Title:    link.Title,
+			Subtitle: link.Description,
+			ImageURL: link.ImageURL,
+			Buttons:  []map[string]string{{"type": "web_url", "url": link.URL, "title": tr(senderID, "button.open")}},
+		}
+		if card.Title == "" {
+			card.Title = link.URL
+		}
+		if card.Subtitle == "" {
+			card.Subtitle = link.URL
+		}
+		if card.ImageURL == "" {
+			card.ImageURL = link.FaviconURL
+		}
+	case models.ContentTypeLocation:
+		var location models.LocationPayload
+		if err := models.DecodePayload(content.Data, &location); err != nil {
+			return err
+		}
+		card = templates.Card{
+			Title:    location.Title,
+			Subtitle: content.Text(userLocale(senderID)),
+			Buttons:  []map[string]string{{"type": "web_url", "url": location.MapURL(), "title": tr(senderID, "button.open_map")}},
+		}
+		if card.Title == "" {
+			card.Title = tr(senderID, "entry.location")
+		}
+	case models.ContentTypeContact:
+		var contact models.ContactPayload
+		if err := models.DecodePayload(content.Data, &contact); err != nil {
+			return err
+		}
+		card = templates.Card{Title: contact.Name, Subtitle: strings.TrimSpace(contact.Phone + " " + contact.Email)}
+		if contact.Phone != "" {
+			card.Buttons = []map[string]string{{"type": "phone_number", "title": tr(senderID, "button.call"), "payload": contact.Phone}}
+		}
+	default:
+		return nil
+	}
+	return services.SendMessage(senderID, templates.CardCarouselTemplate(senderID, []templates.Card{card}))
+}
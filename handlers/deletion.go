@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"gorm.io/gorm"
+)
+
+// DeletionStatusPath is where DeletionStatus is served.
+const DeletionStatusPath = "/data-deletion/status"
+
+// DataDeletion is Facebook's data deletion request callback. It verifies the
+// signed_request, records the request and deletes the user's data in the
+// background, answering with the status page URL and confirmation code
+// Facebook shows the user.
+//
+// Facebook sends the app-scoped user ID, which is used as the sender ID.
+// Users whose Messenger ID differs can still use Delete everything in the bot.
+func DataDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	signed, err := services.ParseSignedRequest(r.FormValue("signed_request"), os.Getenv("APP_SECRET"))
+	if err != nil {
+		log.Printf("Rejected data deletion request: %v", err)
+		http.Error(w, "invalid signed_request", http.StatusBadRequest)
+		return
+	}
+
+	request, err := database.CreateDeletionRequest(signed.UserID)
+	if err != nil {
+		log.Printf("Failed to record data deletion request: %v", err)
+		http.Error(w, "could not record the request", http.StatusInternalServerError)
+		return
+	}
+	go runDeletionRequest(request)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]string{
+		"url":               deletionStatusURL(r, request.Code),
+		"confirmation_code": request.Code,
+	})
+	if err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+// ResumeDeletionRequests runs the deletion requests that were cut short by a
+// restart or failed before.
+func ResumeDeletionRequests() {
+	requests, err := database.UnfinishedDeletionRequests()
+	if err != nil {
+		log.Printf("Failed to load unfinished deletion requests: %v", err)
+		return
+	}
+	for _, request := range requests {
+		runDeletionRequest(request)
+	}
+}
+
+func runDeletionRequest(request models.DeletionRequest) {
+	status := models.DeletionRequestDone
+	if _, err := DeleteUserData(request.SenderID, models.DeletionByFacebook); err != nil {
+		log.Printf("Data deletion request %s failed: %v", request.Code, err)
+		status = models.DeletionRequestFailed
+	}
+	if err := database.FinishDeletionRequest(request.ID, status); err != nil {
+		log.Printf("Failed to update data deletion request %s: %v", request.Code, err)
+	}
+}
+
+// deletionStatusURL is the status page of a request, under PUBLIC_URL or
+// else the host the callback was sent to.
+func deletionStatusURL(r *http.Request, code string) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_URL"), "/")
+	if base == "" {
+		scheme := r.Header.Get("X-Forwarded-Proto")
+		if scheme == "" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + DeletionStatusPath + "?code=" + url.QueryEscape(code)
+}
+
+// DeletionStatus tells the user how their data deletion request is going.
+func DeletionStatus(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	request, err := database.GetDeletionRequest(code)
+	if code == "" || errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Unknown confirmation code.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up data deletion request: %v", err)
+		http.Error(w, "Could not look up the request. Please try again later.", http.StatusInternalServerError)
+		return
+	}
+
+	status := "Your data is being deleted."
+	switch request.Status {
+	case models.DeletionRequestDone:
+		status = "Your data has been deleted."
+	case models.DeletionRequestFailed:
+		status = "Deleting your data failed. It will be tried again; contact us with your confirmation code if this persists."
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Data deletion request %s\n\n%s\n\nRequested: %s\n", request.Code, status, request.CreatedAt.UTC().Format(time.RFC1123))
+	if request.CompletedAt != nil && request.Status == models.DeletionRequestDone {
+		fmt.Fprintf(w, "Completed: %s\n", request.CompletedAt.UTC().Format(time.RFC1123))
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+)
+
+const (
+	digestEntriesPerPage = 10
+	digestPreviewLength  = 60
+	openEntryPrefix      = "OPEN_ENTRY_"
+)
+
+// renderDigest lists entries as one-line previews grouped under the day they
+// were stored.
+func renderDigest(contents []models.Content, format utils.TimeFormat) string {
+	var b strings.Builder
+	day := ""
+	for _, content := range contents {
+		if d := format.Day(content.Timestamp); d != day {
+			if day != "" {
+				b.WriteString("\n")
+			}
+			day = d
+			b.WriteString("*" + day + "*\n")
+		}
+		preview := utils.Truncate(strings.Join(strings.Fields(content.Text(format.Language)), " "), digestPreviewLength)
+		fmt.Fprintf(&b, "• %s  %s\n", format.Time(content.Timestamp), preview)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sendDigest sends a page of entries as a single digest message followed by a
+// carousel of previews, each with an "Open" button for the full entry.
+func sendDigest(senderID string, contents []models.Content) error {
+	if len(contents) == 0 {
+		return nil
+	}
+	format := userTimeFormat(senderID)
+	if err := sendText(senderID, renderDigest(contents, format)); err != nil {
+		return err
+	}
+
+	cards := make([]templates.Card, 0, len(contents))
+	for _, content := range contents {
+		cards = append(cards, templates.Card{
+			Title:    strings.Join(strings.Fields(content.Text(format.Language)), " "),
+			Subtitle: format.Timestamp(content.Timestamp),
+			Buttons: []map[string]string{
+				{"type": "postback", "title": tr(senderID, "button.open"), "payload": fmt.Sprintf("%s%d", openEntryPrefix, content.ID)},
+			},
+		})
+	}
+	return services.SendMessage(senderID, templates.CardCarouselTemplate(senderID, cards))
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+// parseIDPayload extracts the numeric ID from payloads such as EDIT_ENTRY_42.
+func parseIDPayload(payload, prefix string) (uint, bool) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(payload, prefix), 10, 64)
+	if err != nil || id == 0 {
+		log.Printf("Invalid ID in payload: %s", payload)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// sendEntryNotFound is the reply for entry IDs that don't exist or aren't the
+// sender's; both cases look the same to the user.
+func sendEntryNotFound(senderID string) error {
+	userState[senderID] = "waiting_for_action"
+	return sendText(senderID, tr(senderID, "entry.not_found"))
+}
+
+func handleEditEntry(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if !canWrite(senderID, content.StorageContentID) {
+		return sendReadOnly(senderID)
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return sendStorageLocked(senderID, content.StorageContentID)
+	}
+	if content.Type == models.ContentTypeMedia {
+		return sendText(senderID, tr(senderID, "entry.media_not_editable"))
+	}
+
+	getSession(senderID).entryID = content.ID
+	userState[senderID] = "editing_entry"
+	return sendText(senderID, tr(senderID, "entry.current_text")+"\n"+content.Text(userLocale(senderID))+"\n\n"+tr(senderID, "entry.send_new_text"))
+}
+
+// handleEditEntryInput saves the text sent while in the "editing_entry" state.
+func handleEditEntryInput(senderID, data string) error {
+	if strings.TrimSpace(data) == "" {
+		return sendText(senderID, tr(senderID, "entry.send_new_text"))
+	}
+
+	text := data
+	contentType, data, err := classifyText(text)
+	if err != nil {
+		return err
+	}
+
+	sess := getSession(senderID)
+	err = database.UpdateContent(senderID, sess.entryID, contentType, data)
+	if err == nil {
+		err = database.AddTags(senderID, sess.entryID, utils.ParseHashtags(text))
+	}
+	sess.entryID = 0
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if errors.Is(err, database.ErrMediaEntry) {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "entry.media_not_editable"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "entry.update_failed"))
+		return err
+	}
+
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "entry.updated", "data", models.DataText(userLocale(senderID), contentType, data))); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
+func handleDeleteEntry(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if !canWrite(senderID, content.StorageContentID) {
+		return sendReadOnly(senderID)
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return sendStorageLocked(senderID, content.StorageContentID)
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateConfirmDeleteEntry(senderID, userLocale(senderID), contentID))
+}
+
+func handleConfirmDeleteEntry(senderID string, contentID uint) error {
+	lockedID, err := lockedEntryStorage(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+
+	err = database.DeleteContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "entry.delete_failed"))
+		return err
+	}
+
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "entry.deleted")); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/export"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxExportSize is the largest file Messenger accepts as an attachment.
+	maxExportSize = 25 << 20
+	// exportProgressInterval is how often a running export reports progress.
+	exportProgressInterval = 10 * time.Second
+)
+
+// exporting holds the senders with an export running, so each runs one at a
+// time. Exports run outside mu, so it has its own lock.
+var (
+	exporting   = map[string]bool{}
+	exportingMu sync.Mutex
+)
+
+// handleExport asks which format to export a storage in; storageID 0 exports
+// every storage.
+func handleExport(senderID string, storageID uint) error {
+	text := tr(senderID, "export.prompt_all")
+	if storageID != 0 {
+		index := storageIndexByID(senderID, storageID)
+		if index < 0 {
+			return sendText(senderID, tr(senderID, "storage.not_found"))
+		}
+		text = tr(senderID, "export.prompt", "storage", userStorage[senderID][index].StorageName)
+	}
+
+	replies := []map[string]string{}
+	for _, format := range export.Formats {
+		replies = append(replies, map[string]string{
+			"title":   tr(senderID, "export.format."+format),
+			"payload": fmt.Sprintf("EXPORT_%d_%s", storageID, format),
+		})
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, replies))
+}
+
+// startExport collects the storages to export and renders them in the
+// background: a storage goes with everything nested in it, and storageID 0
+// exports every storage. The file is sent when it is ready.
+func startExport(senderID string, storageID uint, format string) error {
+	if !export.Valid(format) {
+		return sendText(senderID, tr(senderID, "export.usage"))
+	}
+
+	storages := []export.Storage{}
+	for _, storage := range userStorage[senderID] {
+		if storageID != 0 && storage.ID != storageID && !isInside(senderID, storage.ID, storageID) {
+			continue
+		}
+		if isLocked(senderID, storage.ID) {
+			return sendStorageLocked(senderID, storage.ID)
+		}
+		parentID := uint(0)
+		if storage.ParentID != nil {
+			parentID = *storage.ParentID
+		}
+		storages = append(storages, export.Storage{
+			Name:    storage.StorageName,
+			Folders: folderNames(senderID, parentID),
+			Mode:    storage.Mode,
+		})
+	}
+	if len(storages) == 0 {
+		if storageID != 0 {
+			return sendText(senderID, tr(senderID, "storage.not_found"))
+		}
+		return sendText(senderID, tr(senderID, "storage.none"))
+	}
+
+	exportingMu.Lock()
+	busy := exporting[senderID]
+	exporting[senderID] = true
+	exportingMu.Unlock()
+	if busy {
+		return sendText(senderID, tr(senderID, "export.busy"))
+	}
+
+	if err := sendText(senderID, trn(senderID, "export.started", len(storages))); err != nil {
+		log.Printf("Failed to send export progress: %v", err)
+	}
+	doc := export.Document{
+		ExportedAt: time.Now(),
+		Storages:   storages,
+		Location:   userTimeFormat(senderID).Location,
+	}
+	go runExport(senderID, userLocale(senderID), doc, format)
+	return nil
+}
+
+// runExport fills in the document's entries, renders it and sends the file.
+// It runs outside mu, so it only reads from the database.
+func runExport(senderID, locale string, doc export.Document, format string) {
+	defer func() {
+		exportingMu.Lock()
+		delete(exporting, senderID)
+		exportingMu.Unlock()
+	}()
+
+	if err := sendExport(senderID, locale, doc, format); err != nil {
+		log.Printf("Export for senderID %s failed: %v", senderID, err)
+		sendText(senderID, i18n.T(locale, "export.failed"))
+	}
+}
+
+func sendExport(senderID, locale string, doc export.Document, format string) error {
+	lastProgress := time.Now()
+	storages := doc.Storages[:0]
+	for i, storage := range doc.Storages {
+		contents, err := database.GetStorageData(senderID, storage.Name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Removed or renamed since the export started.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, content := range contents {
+			entry, err := exportEntry(locale, content, format == export.ZIP)
+			if err != nil {
+				return err
+			}
+			storage.Entries = append(storage.Entries, entry)
+		}
+		storages = append(storages, storage)
+
+		if time.Since(lastProgress) >= exportProgressInterval && i+1 < len(doc.Storages) {
+			lastProgress = time.Now()
+			sendText(senderID, i18n.N(locale, "export.progress", len(doc.Storages), "done", i+1))
+		}
+	}
+	doc.Storages = storages
+
+	data, fileName, mimeType, err := export.Render(doc, format)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxExportSize {
+		return sendText(senderID, i18n.T(locale, "export.too_large"))
+	}
+	attachmentID, err := services.UploadAttachment("file", fileName, mimeType, data)
+	if err != nil {
+		return err
+	}
+	if err := services.SendMessage(senderID, services.AttachmentMessage(senderID, "file", attachmentID)); err != nil {
+		return err
+	}
+	return sendText(senderID, i18n.T(locale, "export.done"))
+}
+
+// exportEntry converts a decrypted entry, with its text in locale, reading
+// its attached file when withFiles is set.
+func exportEntry(locale string, content models.Content, withFiles bool) (export.Entry, error) {
+	entry := export.Entry{
+		ID:        content.ID,
+		Timestamp: content.Timestamp,
+		Type:      content.Type,
+		Text:      content.Text(locale),
+		Done:      content.Done,
+	}
+	if content.Type != models.ContentTypeText && content.Type != models.ContentTypeMedia {
+		entry.Data = content.Data
+	}
+	for _, tag := range content.Tags {
+		entry.Tags = append(entry.Tags, tag.Name)
+	}
+	if content.Attachment != nil {
+		entry.File = &export.File{
+			Name:     content.Attachment.FileName,
+			MimeType: content.Attachment.MimeType,
+			Size:     content.Attachment.Size,
+		}
+		if entry.File.Name == "" {
+			entry.File.Name = content.Attachment.Type
+		}
+		if withFiles {
+			data, err := database.GetAttachmentData(*content.Attachment)
+			if err != nil {
+				return export.Entry{}, err
+			}
+			entry.File.Data = data
+		}
+	}
+	return entry, nil
+}
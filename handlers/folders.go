@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+const (
+	openFolderPrefix = "OPEN_FOLDER_"
+	folderIcon       = "📁 "
+)
+
+// storageIndexByID returns the position of a storage in the user's cache,
+// or -1 when there is none.
+func storageIndexByID(senderID string, storageID uint) int {
+	for i, storage := range userStorage[senderID] {
+		if storage.ID == storageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// childIndexes returns the cache positions of the storages directly inside
+// a folder; folderID 0 is the top level.
+func childIndexes(senderID string, folderID uint) []int {
+	indexes := []int{}
+	for i, storage := range userStorage[senderID] {
+		parentID := uint(0)
+		if storage.ParentID != nil {
+			parentID = *storage.ParentID
+		}
+		if parentID == folderID {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func isFolder(senderID string, storageID uint) bool {
+	return storageID != 0 && len(childIndexes(senderID, storageID)) > 0
+}
+
+// folderNames returns the names of a folder and the folders around it,
+// outermost first; folderID 0 is the top level and has none.
+func folderNames(senderID string, folderID uint) []string {
+	names := []string{}
+	// Bound the walk by the number of storages in case the cache holds a cycle.
+	for i := 0; folderID != 0 && i <= len(userStorage[senderID]); i++ {
+		index := storageIndexByID(senderID, folderID)
+		if index < 0 {
+			break
+		}
+		storage := userStorage[senderID][index]
+		names = append([]string{storage.StorageName}, names...)
+		folderID = 0
+		if storage.ParentID != nil {
+			folderID = *storage.ParentID
+		}
+	}
+	return names
+}
+
+// breadcrumb renders the path to a folder, e.g. "Home › Work › Projects".
+func breadcrumb(senderID string, folderID uint) string {
+	return strings.Join(append([]string{tr(senderID, "folder.home")}, folderNames(senderID, folderID)...), " › ")
+}
+
+// sendStorageBrowser lists the storages in the folder the user is browsing,
+// starting at startIndex. Folders open further folders; plain storages show
+// their entries.
+func sendStorageBrowser(senderID string, startIndex int) error {
+	sess := getSession(senderID)
+	if sess.folderID != 0 && storageIndexByID(senderID, sess.folderID) < 0 {
+		sess.folderID = 0
+	}
+
+	items := []templates.StorageItem{}
+	for _, index := range childIndexes(senderID, sess.folderID) {
+		storage := userStorage[senderID][index]
+		if isFolder(senderID, storage.ID) {
+			items = append(items, templates.StorageItem{Title: folderIcon + storage.StorageName, Payload: fmt.Sprintf("%s%d", openFolderPrefix, storage.ID)})
+		} else {
+			items = append(items, templates.StorageItem{Title: storage.StorageName, Payload: fmt.Sprintf("STORAGE_%d", index+1)})
+		}
+	}
+
+	if sess.folderID == 0 {
+		if len(items) == 0 {
+			userState[senderID] = "waiting_for_action"
+			if err := sendText(senderID, tr(senderID, "storage.none_found")); err != nil {
+				return err
+			}
+			return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+		}
+		if len(items) <= 3 && startIndex == 0 {
+			return services.SendMessage(senderID, services.ListStoragesMessage(senderID, userLocale(senderID), items))
+		}
+		return services.SendMessage(senderID, templates.StorageCarouselTemplate(senderID, userLocale(senderID), items, startIndex, breadcrumb(senderID, 0), "", ""))
+	}
+
+	openPayload := fmt.Sprintf("STORAGE_%d", storageIndexByID(senderID, sess.folderID)+1)
+	return services.SendMessage(senderID, templates.StorageCarouselTemplate(senderID, userLocale(senderID), items, startIndex, breadcrumb(senderID, sess.folderID), "FOLDER_UP_PAYLOAD", openPayload))
+}
+
+func handleOpenFolder(senderID string, folderID uint) error {
+	if storageIndexByID(senderID, folderID) < 0 {
+		return sendText(senderID, tr(senderID, "folder.not_found"))
+	}
+	getSession(senderID).folderID = folderID
+	userState[senderID] = "searching"
+	return sendStorageBrowser(senderID, 0)
+}
+
+func handleFolderUp(senderID string) error {
+	sess := getSession(senderID)
+	if index := storageIndexByID(senderID, sess.folderID); index >= 0 && userStorage[senderID][index].ParentID != nil {
+		sess.folderID = *userStorage[senderID][index].ParentID
+	} else {
+		sess.folderID = 0
+	}
+	return sendStorageBrowser(senderID, 0)
+}
+
+// handleStorageOptions offers actions on the storage the user has open. Only
+// the owner of a shared storage can change the storage itself, and a locked
+// storage asks for its PIN first.
+func handleStorageOptions(senderID string) error {
+	index := getSession(senderID).storageIndex
+	if index >= len(userStorage[senderID]) {
+		return sendText(senderID, tr(senderID, "storage.select_first"))
+	}
+	storage := userStorage[senderID][index]
+	if isLocked(senderID, storage.ID) {
+		return askPIN(senderID, storage.ID, pinActionOpen)
+	}
+	if storage.SenderID != senderID {
+		return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "options.prompt_shared", "storage", storage.StorageName), []map[string]string{
+			{"title": tr(senderID, "button.remind_me"), "payload": fmt.Sprintf("REMIND_STORAGE_%d", storage.ID)},
+			{"title": tr(senderID, "options.export"), "payload": fmt.Sprintf("EXPORT_STORAGE_%d", storage.ID)},
+			{"title": tr(senderID, "options.leave"), "payload": fmt.Sprintf("LEAVE_SHARE_%d", storage.ID)},
+		}))
+	}
+	modeTitle := tr(senderID, "options.make_checklist")
+	if storage.Mode == models.StorageModeChecklist {
+		modeTitle = tr(senderID, "options.make_plain")
+	}
+	replies := []map[string]string{
+		{"title": modeTitle, "payload": fmt.Sprintf("STORAGE_MODE_%d", storage.ID)},
+		{"title": tr(senderID, "options.rename"), "payload": fmt.Sprintf("RENAME_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "button.remind_me"), "payload": fmt.Sprintf("REMIND_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.move_to_folder"), "payload": fmt.Sprintf("MOVE_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.merge_into"), "payload": fmt.Sprintf("MERGE_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.export"), "payload": fmt.Sprintf("EXPORT_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.import"), "payload": fmt.Sprintf("IMPORT_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.share"), "payload": fmt.Sprintf("SHARE_STORAGE_%d", storage.ID)},
+		{"title": tr(senderID, "options.remove_storage"), "payload": fmt.Sprintf("REMOVE_STORAGE_ID_%d", storage.ID)},
+	}
+	replies = append(replies, pinOptions(senderID, storage)...)
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "options.prompt", "storage", storage.StorageName), replies))
+}
+
+// handleMoveStorage asks where to move a storage, offering every storage of
+// the sender's own that isn't the storage itself or nested inside it.
+func handleMoveStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+
+	replies := []map[string]string{}
+	if storage.ParentID != nil {
+		replies = append(replies, map[string]string{"title": tr(senderID, "folder.top_level"), "payload": fmt.Sprintf("MOVE_STORAGE_%d_TO_0", storageID)})
+	}
+	for _, target := range userStorage[senderID] {
+		if target.SenderID != senderID || target.ID == storageID || (storage.ParentID != nil && target.ID == *storage.ParentID) || isInside(senderID, target.ID, storageID) {
+			continue
+		}
+		replies = append(replies, map[string]string{"title": folderIcon + target.StorageName, "payload": fmt.Sprintf("MOVE_STORAGE_%d_TO_%d", storageID, target.ID)})
+	}
+	if len(replies) == 0 {
+		return sendText(senderID, tr(senderID, "folder.nowhere", "storage", storage.StorageName))
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "folder.move_prompt", "storage", storage.StorageName), replies))
+}
+
+// isInside reports whether storageID is nested, at any depth, in folderID.
+func isInside(senderID string, storageID, folderID uint) bool {
+	for i := 0; i <= len(userStorage[senderID]); i++ {
+		index := storageIndexByID(senderID, storageID)
+		if index < 0 || userStorage[senderID][index].ParentID == nil {
+			return false
+		}
+		storageID = *userStorage[senderID][index].ParentID
+		if storageID == folderID {
+			return true
+		}
+	}
+	return false
+}
+
+// moveStorage moves a storage into a folder (0 for the top level) in the
+// database and the cache.
+func moveStorage(senderID string, storageID, folderID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if err := database.MoveStorage(senderID, storageID, folderID); err != nil {
+		return err
+	}
+	if folderID == 0 {
+		userStorage[senderID][index].ParentID = nil
+	} else {
+		userStorage[senderID][index].ParentID = &folderID
+	}
+	return nil
+}
+
+func handleMoveStorageTo(senderID string, storageID, folderID uint) error {
+	err := moveStorage(senderID, storageID, folderID)
+	if errors.Is(err, database.ErrInvalidMove) {
+		return sendText(senderID, tr(senderID, "folder.into_itself"))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "folder.move_failed"))
+		return err
+	}
+	return sendText(senderID, tr(senderID, "folder.moved", "path", breadcrumb(senderID, folderID)))
+}
+
+// confirmRemoveStorage asks before removing a storage, saying how much
+// would go with it.
+func confirmRemoveStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if lockedID := lockedInTree(senderID, storageID); lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+	storages, entries, err := database.CountStorageTree(senderID, storageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	storage := userStorage[senderID][index]
+	text := trn(senderID, "remove.confirm", int(entries), "storage", storage.StorageName)
+	if storages > 1 {
+		text = trn(senderID, "remove.confirm_tree", int(entries), "storage", storage.StorageName, "storages", storages)
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateConfirmRemoveStorage(senderID, userLocale(senderID), text+" "+tr(senderID, "remove.irreversible"), storageID))
+}
+
+func handleConfirmRemoveStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if lockedID := lockedInTree(senderID, storageID); lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+	storageName := userStorage[senderID][index].StorageName
+	log.Printf("Removing storage: %s for senderID: %s", storageName, senderID)
+	if err := removeStorage(senderID, storageID); err != nil {
+		sendText(senderID, tr(senderID, "remove.failed"))
+		return err
+	}
+
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "remove.done", "storage", storageName)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
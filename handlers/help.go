@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/help"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+)
+
+const (
+	helpTopicPrefix = "HELP_TOPIC_"
+	helpMorePrefix  = "HELP_MORE_"
+	// helpTopicsPerPage leaves room for "More…" within Messenger's 13 quick
+	// replies.
+	helpTopicsPerPage = 12
+)
+
+// handleHelp shows the help page matching what the user is doing right now.
+func handleHelp(senderID string) error {
+	topic, ok := help.ForState(userLocale(senderID), userState[senderID])
+	if !ok {
+		return sendText(senderID, tr(senderID, "help.unavailable")+"\n\n"+helpText(userLocale(senderID)))
+	}
+	return sendHelpTopic(senderID, topic)
+}
+
+func handleHelpTopic(senderID, id string) error {
+	topic, ok := help.Lookup(userLocale(senderID), id)
+	if !ok {
+		return handleHelp(senderID)
+	}
+	return sendHelpTopic(senderID, topic)
+}
+
+// sendHelpTopic sends a help page followed by quick replies for browsing the
+// other topics.
+func sendHelpTopic(senderID string, topic help.Topic) error {
+	body := strings.ReplaceAll(topic.Body, "{commands}", helpText(userLocale(senderID)))
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, "*"+topic.Title+"*\n\n"+body, helpTopicReplies(senderID, topic.ID, 0)))
+}
+
+// handleHelpMore lists the next page of topics from a HELP_MORE_<start>_<topic>
+// payload, where topic is the page being read and isn't listed.
+func handleHelpMore(senderID, arg string) error {
+	startText, exclude, _ := strings.Cut(arg, "_")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 0 {
+		start = 0
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "help.more_topics"), helpTopicReplies(senderID, exclude, start)))
+}
+
+// helpTopicReplies returns quick replies for the topics other than exclude,
+// a page at a time from start, ending in "More…" while topics remain.
+func helpTopicReplies(senderID, exclude string, start int) []map[string]string {
+	topics := []help.Topic{}
+	for _, topic := range help.Topics(userLocale(senderID)) {
+		if topic.ID != exclude {
+			topics = append(topics, topic)
+		}
+	}
+	if start >= len(topics) {
+		start = 0
+	}
+	end := min(start+helpTopicsPerPage, len(topics))
+
+	replies := []map[string]string{}
+	for _, topic := range topics[start:end] {
+		replies = append(replies, map[string]string{
+			"title":   topic.Title,
+			"payload": helpTopicPrefix + topic.ID,
+		})
+	}
+	if end < len(topics) {
+		replies = append(replies, map[string]string{
+			"title":   tr(senderID, "help.more"),
+			"payload": fmt.Sprintf("%s%d_%s", helpMorePrefix, end, exclude),
+		})
+	}
+	return replies
+}
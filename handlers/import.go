@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/export"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/importer"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+// maxImportEntries caps how many entries one file may import.
+const maxImportEntries = 5000
+
+// importPlan is what a file will import once the user confirms it.
+type importPlan struct {
+	storages []database.ImportStorage
+	entries  int
+}
+
+// handleImport asks for the file to import. Its entries go into the storage
+// with storageID, or with storageID 0 into the storages the file names.
+func handleImport(senderID string, storageID uint) error {
+	text := tr(senderID, "import.prompt_all")
+	if storageID != 0 {
+		index := storageIndexByID(senderID, storageID)
+		if index < 0 {
+			return sendText(senderID, tr(senderID, "storage.not_found"))
+		}
+		if !canWrite(senderID, storageID) {
+			return sendReadOnly(senderID)
+		}
+		text = tr(senderID, "import.prompt", "storage", userStorage[senderID][index].StorageName)
+	}
+
+	sess := getSession(senderID)
+	sess.importStorageID = storageID
+	sess.importPlan = nil
+	userState[senderID] = "importing"
+	return sendImportPrompt(senderID, text)
+}
+
+func sendImportPrompt(senderID, text string) error {
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, []map[string]string{
+		{"title": tr(senderID, "button.cancel"), "payload": "CANCEL_IMPORT_PAYLOAD"},
+	}))
+}
+
+// handleImportFile reads a file sent in the "importing" state and sends a
+// summary of what importing it would store, with a button to go ahead.
+// Nothing is stored until the user confirms.
+func handleImportFile(senderID string, attachments []models.MessageAttachment) error {
+	var file *models.MessageAttachment
+	for i := range attachments {
+		if attachments[i].Type == "file" {
+			file = &attachments[i]
+			break
+		}
+	}
+	if file == nil {
+		return sendImportPrompt(senderID, tr(senderID, "import.file_expected"))
+	}
+
+	data, mimeType, fileName, err := services.DownloadAttachment(file.Payload.URL)
+	if err != nil {
+		log.Printf("Failed to download import file for senderID %s: %v", senderID, err)
+		return sendImportPrompt(senderID, tr(senderID, "import.download_failed"))
+	}
+	storages, err := importer.Parse(fileName, mimeType, data, time.Now())
+	if errors.Is(err, importer.ErrEmpty) {
+		return sendImportPrompt(senderID, tr(senderID, "import.empty"))
+	}
+	if err != nil {
+		log.Printf("Failed to parse import file %q for senderID %s: %v", fileName, senderID, err)
+		return sendImportPrompt(senderID, tr(senderID, "import.unsupported"))
+	}
+
+	sess := getSession(senderID)
+	targetName := ""
+	if sess.importStorageID != 0 {
+		index := storageIndexByID(senderID, sess.importStorageID)
+		if index < 0 {
+			userState[senderID] = "waiting_for_action"
+			return sendText(senderID, tr(senderID, "storage.not_found"))
+		}
+		targetName = userStorage[senderID][index].StorageName
+	}
+
+	plan, duplicates, skipped, err := planImport(senderID, storages, targetName, importer.StorageName(fileName))
+	if err != nil {
+		return err
+	}
+	if plan.entries > maxImportEntries {
+		return sendImportPrompt(senderID, tr(senderID, "import.too_many", "max", maxImportEntries))
+	}
+
+	lines := []string{}
+	if plan.entries == 0 {
+		lines = append(lines, tr(senderID, "import.nothing_new"))
+	} else {
+		lines = append(lines, trn(senderID, "import.summary", plan.entries))
+		for _, storage := range plan.storages {
+			key := "import.into_new_storage"
+			if findStorageIndex(senderID, storage.Name) >= 0 {
+				key = "import.into_storage"
+			}
+			lines = append(lines, trn(senderID, key, len(storage.Entries), "storage", storage.Name))
+		}
+	}
+	if duplicates > 0 {
+		lines = append(lines, trn(senderID, "import.duplicates", duplicates))
+	}
+	if skipped > 0 {
+		lines = append(lines, trn(senderID, "import.skipped_files", skipped))
+	}
+	text := strings.Join(lines, "\n")
+
+	if plan.entries == 0 {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, text)
+	}
+	sess.importPlan = &plan
+	return services.SendMessage(senderID, templates.ButtonTemplateConfirmImport(senderID, userLocale(senderID), text+"\n\n"+tr(senderID, "import.confirm")))
+}
+
+// planImport sorts a file's entries into the storages they go to: targetName
+// when set, otherwise the storage each names, or defaultName. Entries already
+// stored in their storage, or repeated in the file, are left out, and so are
+// entries of files, which can't be imported without the file itself.
+func planImport(senderID string, storages []export.Storage, targetName, defaultName string) (plan importPlan, duplicates, skipped int, err error) {
+	byName := map[string]int{}
+	seen := map[string]map[string]bool{}
+	for _, storage := range storages {
+		name, mode := targetName, ""
+		if name == "" {
+			name, mode = strings.TrimSpace(storage.Name), storage.Mode
+			if name == "" {
+				name = defaultName
+			}
+			if index := findStorageIndex(senderID, name); index >= 0 {
+				name = userStorage[senderID][index].StorageName
+			}
+		}
+
+		key := strings.ToLower(name)
+		i, ok := byName[key]
+		if !ok {
+			existing, err := existingEntries(senderID, name)
+			if err != nil {
+				return importPlan{}, 0, 0, err
+			}
+			if mode != models.StorageModeChecklist {
+				mode = models.StorageModeNotes
+			}
+			i = len(plan.storages)
+			byName[key] = i
+			seen[key] = existing
+			plan.storages = append(plan.storages, database.ImportStorage{Name: name, Mode: mode})
+		}
+
+		for _, entry := range storage.Entries {
+			if entry.Type == models.ContentTypeMedia || entry.File != nil {
+				skipped++
+				continue
+			}
+			contentType, data := entry.Type, entry.Data
+			if contentType == models.ContentTypeText || data == "" {
+				contentType, data = models.ContentTypeText, entry.Text
+			}
+			if seen[key][contentType+"\x00"+data] {
+				duplicates++
+				continue
+			}
+			seen[key][contentType+"\x00"+data] = true
+
+			tags := append(append([]string(nil), entry.Tags...), utils.ParseHashtags(entry.Text)...)
+			plan.storages[i].Entries = append(plan.storages[i].Entries, database.ImportEntry{
+				Timestamp: entry.Timestamp,
+				Type:      contentType,
+				Data:      data,
+				Done:      entry.Done,
+				Tags:      uniqueTags(tags),
+			})
+			plan.entries++
+		}
+	}
+
+	storagesWithEntries := plan.storages[:0]
+	for _, storage := range plan.storages {
+		if len(storage.Entries) > 0 {
+			storagesWithEntries = append(storagesWithEntries, storage)
+		}
+	}
+	plan.storages = storagesWithEntries
+	return plan, duplicates, skipped, nil
+}
+
+// existingEntries returns the type and data of every entry in a storage, for
+// spotting duplicates. A storage that doesn't exist yet has none.
+func existingEntries(senderID, storageName string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	contents, err := database.GetStorageData(senderID, storageName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, content := range contents {
+		existing[content.Type+"\x00"+content.Data] = true
+	}
+	return existing, nil
+}
+
+func uniqueTags(names []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = utils.NormalizeTag(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// handleConfirmImport stores the entries of the file the user confirmed, all
+// in one transaction.
+func handleConfirmImport(senderID string) error {
+	sess := getSession(senderID)
+	plan := sess.importPlan
+	if plan == nil {
+		return sendText(senderID, tr(senderID, "import.expired"))
+	}
+	sess.importPlan = nil
+	userState[senderID] = "waiting_for_action"
+
+	created, err := database.ImportEntries(senderID, plan.storages)
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "import.failed"))
+		return err
+	}
+	userStorage[senderID] = append(userStorage[senderID], created...)
+	for _, storage := range plan.storages {
+		if index := findStorageIndex(senderID, storage.Name); index >= 0 {
+			storageName, count := userStorage[senderID][index].StorageName, len(storage.Entries)
+			notifyMembers(senderID, userStorage[senderID][index].ID, func(locale, name string) string {
+				return i18n.N(locale, "share.entries_imported", count, "name", name, "storage", storageName)
+			})
+		}
+	}
+	log.Printf("Imported %d entries for senderID %s", plan.entries, senderID)
+
+	if err := sendText(senderID, trn(senderID, "import.done", plan.entries)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
+
+func handleCancelImport(senderID string) error {
+	getSession(senderID).importPlan = nil
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "import.cancelled")); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+// What entering a storage's current PIN is for.
+const (
+	pinActionOpen   = "open"
+	pinActionChange = "change"
+	pinActionRemove = "remove"
+)
+
+const (
+	// unlockWindow is how long a storage stays open after its PIN is entered.
+	unlockWindow = 5 * time.Minute
+	// maxPINFailures wrong PINs in a row lock a storage for pinLockout.
+	maxPINFailures = 5
+	pinLockout     = 15 * time.Minute
+	// minPINLength and maxPINLength bound a PIN in characters; bcrypt reads
+	// no more than 72 bytes.
+	minPINLength = 4
+	maxPINLength = 64
+)
+
+// isLocked reports whether a storage has a PIN the sender hasn't entered in
+// the last unlockWindow.
+func isLocked(senderID string, storageID uint) bool {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 || userStorage[senderID][index].PINHash == "" {
+		return false
+	}
+	return time.Now().After(getSession(senderID).unlockedUntil[storageID])
+}
+
+// sendLockedOut tells the sender how long a storage refuses PINs for, if it
+// does.
+func sendLockedOut(senderID string, storage models.StorageContent) (bool, error) {
+	wait := time.Until(getSession(senderID).lockedOutUntil[storage.ID])
+	if wait <= 0 {
+		return false, nil
+	}
+	userState[senderID] = "waiting_for_action"
+	minutes := int((wait + time.Minute - 1) / time.Minute)
+	return true, sendText(senderID, trn(senderID, "pin.locked_out", minutes, "storage", storage.StorageName))
+}
+
+// askPIN asks for the current PIN of a storage before doing action.
+func askPIN(senderID string, storageID uint, action string) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+	if lockedOut, err := sendLockedOut(senderID, storage); lockedOut {
+		return err
+	}
+
+	sess := getSession(senderID)
+	sess.pinStorageID = storageID
+	sess.pinAction = action
+	userState[senderID] = "entering_pin"
+	return sendPINPrompt(senderID, tr(senderID, "pin.enter", "storage", storage.StorageName))
+}
+
+func sendPINPrompt(senderID, text string) error {
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, []map[string]string{
+		{"title": tr(senderID, "button.cancel"), "payload": "CANCEL_PIN_PAYLOAD"},
+	}))
+}
+
+// handleSetPIN starts locking one of the sender's storages with a PIN. A
+// storage that already has one asks for it first.
+func handleSetPIN(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 || userStorage[senderID][index].SenderID != senderID {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+	if storage.PINHash != "" {
+		return askPIN(senderID, storageID, pinActionChange)
+	}
+	return askNewPIN(senderID, storage)
+}
+
+// handleRemovePIN asks for a storage's PIN before unlocking it for good.
+func handleRemovePIN(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 || userStorage[senderID][index].SenderID != senderID {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	return askPIN(senderID, storageID, pinActionRemove)
+}
+
+func askNewPIN(senderID string, storage models.StorageContent) error {
+	sess := getSession(senderID)
+	sess.pinStorageID = storage.ID
+	sess.newPINHash = ""
+	userState[senderID] = "setting_pin"
+	return sendPINPrompt(senderID, tr(senderID, "pin.new", "storage", storage.StorageName, "min", minPINLength, "max", maxPINLength))
+}
+
+// handlePINInput checks the PIN sent in the "entering_pin" state and carries
+// on with what it was asked for.
+func handlePINInput(senderID, pin string) error {
+	sess := getSession(senderID)
+	index := storageIndexByID(senderID, sess.pinStorageID)
+	if index < 0 {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+	if lockedOut, err := sendLockedOut(senderID, storage); lockedOut {
+		return err
+	}
+	if pin == "" {
+		return sendPINPrompt(senderID, tr(senderID, "pin.enter", "storage", storage.StorageName))
+	}
+
+	ok, err := database.CheckStoragePIN(senderID, storage.ID, pin)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		sess.pinFailures[storage.ID]++
+		if sess.pinFailures[storage.ID] >= maxPINFailures {
+			log.Printf("Too many wrong PINs for storage %d from senderID %s", storage.ID, senderID)
+			delete(sess.pinFailures, storage.ID)
+			sess.lockedOutUntil[storage.ID] = time.Now().Add(pinLockout)
+			_, err := sendLockedOut(senderID, storage)
+			return err
+		}
+		return sendPINPrompt(senderID, trn(senderID, "pin.wrong", maxPINFailures-sess.pinFailures[storage.ID]))
+	}
+	delete(sess.pinFailures, storage.ID)
+
+	switch sess.pinAction {
+	case pinActionChange:
+		return askNewPIN(senderID, storage)
+	case pinActionRemove:
+		userState[senderID] = "waiting_for_action"
+		if err := setStoragePIN(senderID, index, ""); err != nil {
+			sendText(senderID, tr(senderID, "pin.failed"))
+			return err
+		}
+		return sendText(senderID, tr(senderID, "pin.removed", "storage", storage.StorageName))
+	}
+	sess.unlockedUntil[storage.ID] = time.Now().Add(unlockWindow)
+	userState[senderID] = "waiting_for_action"
+	return handleStorageSelection(senderID, index+1)
+}
+
+// handleNewPINInput takes the new PIN sent in the "setting_pin" state and
+// asks for it again.
+func handleNewPINInput(senderID, pin string) error {
+	if n := utf8.RuneCountInString(pin); n < minPINLength || n > maxPINLength || len(pin) > 72 {
+		return sendPINPrompt(senderID, tr(senderID, "pin.invalid", "min", minPINLength, "max", maxPINLength))
+	}
+	hash, err := utils.HashPIN(pin)
+	if err != nil {
+		return err
+	}
+	getSession(senderID).newPINHash = hash
+	userState[senderID] = "confirming_pin"
+	return sendPINPrompt(senderID, tr(senderID, "pin.confirm"))
+}
+
+// handleConfirmPINInput locks the storage once the PIN sent in the
+// "confirming_pin" state matches the new one.
+func handleConfirmPINInput(senderID, pin string) error {
+	sess := getSession(senderID)
+	if !utils.PINMatches(sess.newPINHash, pin) {
+		sess.newPINHash = ""
+		userState[senderID] = "setting_pin"
+		return sendPINPrompt(senderID, tr(senderID, "pin.mismatch"))
+	}
+	index := storageIndexByID(senderID, sess.pinStorageID)
+	if index < 0 {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+
+	userState[senderID] = "waiting_for_action"
+	err := setStoragePIN(senderID, index, sess.newPINHash)
+	sess.newPINHash = ""
+	if err != nil {
+		sendText(senderID, tr(senderID, "pin.failed"))
+		return err
+	}
+	storage := userStorage[senderID][index]
+	sess.unlockedUntil[storage.ID] = time.Now().Add(unlockWindow)
+	log.Printf("Set PIN on storage %d for senderID %s", storage.ID, senderID)
+	if err := sendText(senderID, tr(senderID, "pin.set", "storage", storage.StorageName, "minutes", int(unlockWindow/time.Minute))); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
+// setStoragePIN saves a storage's PIN hash in the database and the caches of
+// everyone who can open it.
+func setStoragePIN(senderID string, index int, hash string) error {
+	storage := userStorage[senderID][index]
+	if err := database.SetStoragePIN(senderID, storage.ID, hash); err != nil {
+		return err
+	}
+	userStorage[senderID][index].PINHash = hash
+	refreshSharedStorage(userStorage[senderID][index])
+	return nil
+}
+
+func handleCancelPIN(senderID string) error {
+	sess := getSession(senderID)
+	sess.pinStorageID, sess.pinAction, sess.newPINHash = 0, "", ""
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "pin.cancelled")); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
+
+// sendStorageLocked is the reply when something other than opening a locked
+// storage would show what is in it.
+func sendStorageLocked(senderID string, storageID uint) error {
+	storageName := ""
+	if index := storageIndexByID(senderID, storageID); index >= 0 {
+		storageName = userStorage[senderID][index].StorageName
+	}
+	return sendText(senderID, tr(senderID, "pin.locked_storage", "storage", storageName))
+}
+
+// lockedEntryStorage returns the storage holding an entry if the sender
+// hasn't unlocked it, or 0.
+func lockedEntryStorage(senderID string, contentID uint) (uint, error) {
+	content, err := database.GetContent(senderID, contentID)
+	if err != nil {
+		return 0, err
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return content.StorageContentID, nil
+	}
+	return 0, nil
+}
+
+// lockedInTree returns a storage the sender hasn't unlocked among a storage
+// and those nested in it, or 0.
+func lockedInTree(senderID string, storageID uint) uint {
+	if isLocked(senderID, storageID) {
+		return storageID
+	}
+	for _, storage := range userStorage[senderID] {
+		if isInside(senderID, storage.ID, storageID) && isLocked(senderID, storage.ID) {
+			return storage.ID
+		}
+	}
+	return 0
+}
+
+// withoutLocked drops results from storages the sender hasn't unlocked and
+// says how many were left out.
+func withoutLocked(senderID string, results []database.SearchResult) ([]database.SearchResult, string) {
+	shown := results[:0]
+	hidden := 0
+	for _, result := range results {
+		if isLocked(senderID, result.Content.StorageContentID) {
+			hidden++
+			continue
+		}
+		shown = append(shown, result)
+	}
+	if hidden == 0 {
+		return shown, ""
+	}
+	return shown, trn(senderID, "pin.hidden", hidden)
+}
+
+func pinOptions(senderID string, storage models.StorageContent) []map[string]string {
+	if storage.PINHash == "" {
+		return []map[string]string{
+			{"title": tr(senderID, "options.set_pin"), "payload": fmt.Sprintf("SET_PIN_%d", storage.ID)},
+		}
+	}
+	return []map[string]string{
+		{"title": tr(senderID, "options.change_pin"), "payload": fmt.Sprintf("SET_PIN_%d", storage.ID)},
+		{"title": tr(senderID, "options.remove_pin"), "payload": fmt.Sprintf("REMOVE_PIN_%d", storage.ID)},
+	}
+}
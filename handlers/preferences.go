@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/dateparse"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/utils"
+)
+
+// preferences caches each sender's preferences and the time format made from
+// them, so building a reply doesn't hit the database or the time zone files
+// once per string. It has its own lock because replies are built both with
+// and without mu held. profileTried marks the senders whose Graph profile was
+// already fetched to seed their preferences.
+var (
+	preferences   = map[string]cachedPreference{}
+	profileTried  = map[string]bool{}
+	preferencesMu sync.Mutex
+)
+
+type cachedPreference struct {
+	preference models.UserPreference
+	format     utils.TimeFormat
+}
+
+// cachedPreferences returns the sender's preferences from the cache, loading
+// them the first time. The defaults are used, but not kept, when they can't
+// be read.
+func cachedPreferences(senderID string) cachedPreference {
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	if cached, ok := preferences[senderID]; ok {
+		return cached
+	}
+	preference, err := database.GetUserPreference(senderID)
+	cached := cachedPreference{preference: preference, format: preferenceTimeFormat(preference)}
+	if err != nil {
+		log.Printf("Failed to load preferences for senderID %s: %v", senderID, err)
+		return cached
+	}
+	preferences[senderID] = cached
+	return cached
+}
+
+// forgetProfile lets a sender's Graph profile seed their preferences again,
+// once their data is deleted.
+func forgetProfile(senderID string) {
+	preferencesMu.Lock()
+	delete(profileTried, senderID)
+	preferencesMu.Unlock()
+}
+
+// userPreference is the sender's preferences, or the defaults when they
+// can't be read.
+func userPreference(senderID string) models.UserPreference {
+	return cachedPreferences(senderID).preference
+}
+
+// preferenceLocation is the time zone of a preference, or the server's when
+// none is set or it can't be loaded.
+func preferenceLocation(preference models.UserPreference) *time.Location {
+	if preference.Timezone == "" {
+		return time.Local
+	}
+	location, err := utils.LoadLocation(preference.Timezone)
+	if err != nil {
+		log.Printf("Invalid time zone %q for senderID %s: %v", preference.Timezone, preference.SenderID, err)
+		return time.Local
+	}
+	return location
+}
+
+func preferenceTimeFormat(preference models.UserPreference) utils.TimeFormat {
+	return utils.TimeFormat{
+		Location:  preferenceLocation(preference),
+		Clock24:   preference.Clock == models.Clock24,
+		DateOrder: preference.DateOrder,
+		Language:  i18n.Match(preference.Language),
+	}
+}
+
+// userLocale is the language the sender is spoken to in, as one of the
+// loaded catalogs.
+func userLocale(senderID string) string {
+	return cachedPreferences(senderID).format.Language
+}
+
+// forgetPreferences drops the cached preferences after the sender changes
+// them.
+func forgetPreferences(senderID string) {
+	preferencesMu.Lock()
+	delete(preferences, senderID)
+	preferencesMu.Unlock()
+}
+
+// tr translates a message for the sender; see i18n.T.
+func tr(senderID, key string, args ...interface{}) string {
+	return i18n.T(userLocale(senderID), key, args...)
+}
+
+// trn translates a message that depends on a count; see i18n.N.
+func trn(senderID, key string, count int, args ...interface{}) string {
+	return i18n.N(userLocale(senderID), key, count, args...)
+}
+
+// userTimeFormat is how times are shown to the sender.
+func userTimeFormat(senderID string) utils.TimeFormat {
+	return cachedPreferences(senderID).format
+}
+
+// userDateParser reads date expressions in the sender's language and time
+// zone.
+func userDateParser(senderID string, prefer dateparse.Direction) *dateparse.Parser {
+	cached := cachedPreferences(senderID)
+	return dateparse.New(cached.preference.Language, cached.format.Location, prefer)
+}
+
+// profileToSeed fetches the Graph profile of a sender whose preferences
+// haven't been seeded from it, trying once per sender. It makes a Graph call,
+// so the webhook runs it before taking mu.
+func profileToSeed(senderID string) *services.UserProfile {
+	if userPreference(senderID).ProfileSeeded {
+		return nil
+	}
+	preferencesMu.Lock()
+	tried := profileTried[senderID]
+	profileTried[senderID] = true
+	preferencesMu.Unlock()
+	if tried {
+		return nil
+	}
+
+	profile, err := services.GetUserProfile(senderID)
+	if err != nil {
+		log.Printf("Failed to seed preferences for senderID %s: %v", senderID, err)
+		return nil
+	}
+	return &profile
+}
+
+// seedPreferences fills in a new user's time zone and language from their
+// Graph profile, once. Settings the user chose themselves are kept.
+func seedPreferences(senderID string, profile *services.UserProfile) {
+	if profile == nil {
+		return
+	}
+	preference, err := database.GetUserPreference(senderID)
+	if err != nil || preference.ProfileSeeded {
+		return
+	}
+
+	if preference.Timezone == "" && profile.Timezone != nil {
+		preference.Timezone = utils.UTCOffsetName(*profile.Timezone)
+	}
+	if preference.Language == "" {
+		preference.Language = profile.Locale
+	}
+	if preference.DateOrder == utils.DateOrderMDY && profile.Locale != "" && profile.Locale != "en_US" {
+		preference.DateOrder = utils.DateOrderDMY
+	}
+	preference.ProfileSeeded = true
+	if err := database.SaveUserPreference(preference); err != nil {
+		log.Printf("Failed to save seeded preferences for senderID %s: %v", senderID, err)
+	}
+	forgetPreferences(senderID)
+}
+
+// formatPreferences lists the settings in the language they name.
+func formatPreferences(preference models.UserPreference) string {
+	locale := i18n.Match(preference.Language)
+	timezone := preference.Timezone
+	if timezone == "" {
+		timezone = i18n.T(locale, "settings.server_time", "zone", time.Local.String())
+	}
+	language := preference.Language
+	if language == "" {
+		language = i18n.T(locale, "settings.not_set")
+	}
+	return i18n.T(locale, "settings.summary",
+		"timezone", timezone,
+		"clock", preference.Clock,
+		"date", preference.DateOrder,
+		"language", language,
+		"view", preference.ViewMode,
+		"now", preferenceTimeFormat(preference).Timestamp(time.Now()),
+	)
+}
+
+// setPreference changes one setting and returns the reply for the user, in
+// locale, when the setting can't be changed.
+func setPreference(locale string, preference *models.UserPreference, name, value string) string {
+	switch strings.ToLower(name) {
+	case "timezone", "tz", "zone":
+		location, err := utils.LoadLocation(value)
+		if err != nil {
+			return i18n.T(locale, "settings.bad_timezone", "zone", value)
+		}
+		preference.Timezone = location.String()
+	case "clock", "time":
+		switch strings.TrimSuffix(strings.ToLower(value), "h") {
+		case "12":
+			preference.Clock = models.Clock12
+		case "24":
+			preference.Clock = models.Clock24
+		default:
+			return i18n.T(locale, "settings.clock_usage")
+		}
+	case "date", "dateformat":
+		value = strings.ToLower(value)
+		if value != utils.DateOrderMDY && value != utils.DateOrderDMY && value != utils.DateOrderYMD {
+			return i18n.T(locale, "settings.date_usage")
+		}
+		preference.DateOrder = value
+	case "language", "lang", "locale":
+		preference.Language = value
+	default:
+		return i18n.T(locale, "settings.unknown")
+	}
+	return ""
+}
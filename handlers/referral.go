@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/markDoesany/quickymessenger/deeplink"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+)
+
+// linkKey signs m.me link refs. It is the encryption key, so links stop
+// working if it changes, just like the data they point to.
+func linkKey() []byte {
+	return []byte(os.Getenv("ENCRYPTION_KEY"))
+}
+
+// linkURL makes an m.me link to the page that starts action with arg, or ""
+// when PAGE_USERNAME isn't set.
+func linkURL(action, arg string) string {
+	page := os.Getenv("PAGE_USERNAME")
+	if page == "" {
+		return ""
+	}
+	ref, err := deeplink.Ref(linkKey(), deeplink.Link{Action: action, Arg: arg})
+	if err != nil {
+		log.Printf("Failed to make %s link: %v", action, err)
+		return ""
+	}
+	return deeplink.URL(page, ref)
+}
+
+// handleReferral does what the m.me link the user followed asks for. Refs
+// the bot didn't sign, such as from ads, just get the main menu.
+func handleReferral(senderID, ref string) error {
+	userState[senderID] = "waiting_for_action"
+	link, err := deeplink.Parse(linkKey(), ref)
+	if err != nil {
+		log.Printf("Ignoring referral %q for senderID %s: %v", ref, senderID, err)
+		return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+	}
+	log.Printf("Following %s link for senderID %s", link.Action, senderID)
+
+	switch link.Action {
+	case deeplink.OpenStorage:
+		index := -1
+		if storageID, err := strconv.ParseUint(link.Arg, 10, 64); err == nil {
+			index = storageIndexByID(senderID, uint(storageID))
+		}
+		if index < 0 {
+			if err := sendText(senderID, tr(senderID, "link.no_access")); err != nil {
+				return err
+			}
+			return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+		}
+		return handleStorageSelection(senderID, index+1)
+	case deeplink.AcceptInvite:
+		return handleJoin(senderID, link.Arg)
+	case deeplink.CreateStorage:
+		userState[senderID] = "creating"
+		if link.Arg != "" {
+			return handleCreateStorageInput(senderID, link.Arg)
+		}
+		return sendText(senderID, tr(senderID, "storage.enter_name"))
+	}
+	return nil
+}
+
+// handleStorageLink sends an m.me link that opens a storage, for the sender
+// and anyone it is shared with.
+func handleStorageLink(senderID string, index int) error {
+	storage := userStorage[senderID][index]
+	url := linkURL(deeplink.OpenStorage, strconv.FormatUint(uint64(storage.ID), 10))
+	if url == "" {
+		return sendText(senderID, tr(senderID, "link.unavailable"))
+	}
+	return sendText(senderID, tr(senderID, "link.open_storage", "storage", storage.StorageName, "url", url))
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/dateparse"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+// reminderHour is when a reminder set for a day without a time goes off.
+const reminderHour = 9
+
+// parseReminderTime reads when a reminder is due from phrases such as
+// "in 2 hours", "tomorrow 9am" or "next friday at 6pm", relative to now.
+// A day without a time means reminderHour in the parser's time zone.
+func parseReminderTime(parser *dateparse.Parser, text string, now time.Time) (time.Time, bool) {
+	text = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(text)), "remind me"))
+	result, err := parser.Parse(text, now)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !result.HasTime {
+		return result.Start.Add(reminderHour * time.Hour), true
+	}
+	return result.Start, true
+}
+
+func handleRemindEntry(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+
+	sess := getSession(senderID)
+	sess.reminderStorageID, sess.reminderContentID = content.StorageContentID, content.ID
+	userState[senderID] = "setting_reminder"
+	return sendText(senderID, tr(senderID, "reminder.prompt"))
+}
+
+func handleRemindStorage(senderID string, storageID uint) error {
+	if storageIndexByID(senderID, storageID) < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+
+	sess := getSession(senderID)
+	sess.reminderStorageID, sess.reminderContentID = storageID, 0
+	userState[senderID] = "setting_reminder"
+	return sendText(senderID, tr(senderID, "reminder.prompt"))
+}
+
+// handleReminderInput schedules the reminder described in the session for
+// the time sent while in the "setting_reminder" state.
+func handleReminderInput(senderID, text string) error {
+	sess := getSession(senderID)
+	if err := scheduleReminder(senderID, sess.reminderStorageID, sess.reminderContentID, text); err != nil || userState[senderID] == "setting_reminder" {
+		return err
+	}
+	sess.reminderStorageID, sess.reminderContentID = 0, 0
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
+
+// scheduleReminder creates a reminder due at the time described by when and
+// tells the user. The state is left unchanged when the time can't be read.
+func scheduleReminder(senderID string, storageID, contentID uint, when string) error {
+	now := time.Now()
+	dueAt, ok := parseReminderTime(userDateParser(senderID, dateparse.Future), when, now)
+	if !ok {
+		return sendText(senderID, tr(senderID, "reminder.bad_time")+" "+tr(senderID, "reminder.prompt"))
+	}
+	if !dueAt.After(now) {
+		return sendText(senderID, tr(senderID, "reminder.past")+" "+tr(senderID, "reminder.prompt"))
+	}
+
+	_, err := database.CreateReminder(senderID, storageID, contentID, dueAt)
+	userState[senderID] = "waiting_for_action"
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "reminder.target_gone"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "reminder.set_failed"))
+		return err
+	}
+	return sendText(senderID, tr(senderID, "reminder.set", "time", userTimeFormat(senderID).Timestamp(dueAt)))
+}
+
+// SendReminder delivers a due reminder. It is called by the scheduler, outside
+// the webhook lock, so it only reads from the database. Reminders whose entry
+// or storage is gone are skipped without error, and those of locked storages
+// leave out what they are about.
+func SendReminder(reminder models.Reminder) error {
+	storage, err := database.GetStorage(reminder.SenderID, reminder.StorageContentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	text := tr(reminder.SenderID, "reminder.heading", "storage", storage.StorageName)
+	if storage.PINHash != "" {
+		text = tr(reminder.SenderID, "reminder.locked", "storage", storage.StorageName)
+	} else if reminder.ContentID != nil {
+		content, err := database.GetContent(reminder.SenderID, *reminder.ContentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		text += ":\n\n" + content.Text(userLocale(reminder.SenderID))
+	} else {
+		contents, err := database.GetStorageData(reminder.SenderID, storage.StorageName)
+		if err != nil {
+			return err
+		}
+		for _, content := range contents {
+			if storage.Mode == models.StorageModeChecklist {
+				text += "\n" + checkBox(content.Done) + " " + content.Text(userLocale(reminder.SenderID))
+			} else {
+				text += "\n- " + content.Text(userLocale(reminder.SenderID))
+			}
+		}
+	}
+
+	// Button templates can't hold long reminders, so send those as plain text first.
+	if len([]rune(text)) > 640 {
+		if err := sendText(reminder.SenderID, text); err != nil {
+			return err
+		}
+		text = tr(reminder.SenderID, "reminder.snooze_prompt")
+	}
+	return services.SendMessage(reminder.SenderID, templates.ReminderTemplate(reminder.SenderID, userLocale(reminder.SenderID), text, reminder.ID))
+}
+
+func handleSnoozeReminder(senderID string, reminderID uint, minutes int) error {
+	dueAt := time.Now().Add(time.Duration(minutes) * time.Minute)
+	err := database.SnoozeReminder(senderID, reminderID, dueAt)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "reminder.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	return sendText(senderID, tr(senderID, "reminder.snoozed", "time", userTimeFormat(senderID).Timestamp(dueAt)))
+}
+
+func handleDismissReminder(senderID string, reminderID uint) error {
+	err := database.DismissReminder(senderID, reminderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "reminder.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	return sendText(senderID, tr(senderID, "reminder.dismissed"))
+}
+
+// formatReminders lists pending reminders with the storages they belong to.
+func formatReminders(senderID string, reminders []models.Reminder) string {
+	format := userTimeFormat(senderID)
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString(tr(senderID, "reminders.heading") + "\n")
+	for _, reminder := range reminders {
+		storageName := tr(senderID, "reminders.removed_storage")
+		if index := storageIndexByID(senderID, reminder.StorageContentID); index >= 0 {
+			storageName = userStorage[senderID][index].StorageName
+		}
+		what := tr(senderID, "reminders.whole_storage")
+		if reminder.ContentID != nil {
+			what = tr(senderID, "reminders.entry")
+		}
+		fmt.Fprintf(&b, "\n%s (%s) - %s, %s", format.Timestamp(reminder.DueAt), format.Relative(reminder.DueAt, now), storageName, what)
+	}
+	return b.String()
+}
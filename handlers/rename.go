@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+// handleRenameStorage asks for a storage's new name, mentioning any names it
+// had before.
+func handleRenameStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storageName := userStorage[senderID][index].StorageName
+
+	text := tr(senderID, "rename.prompt", "storage", storageName)
+	renames, err := database.GetStorageRenames(senderID, storageID)
+	if err != nil {
+		return err
+	}
+	if len(renames) > 0 {
+		oldNames := make([]string, 0, len(renames))
+		for _, rename := range renames {
+			oldNames = append(oldNames, rename.OldName)
+		}
+		text += "\n" + tr(senderID, "rename.previous", "names", strings.Join(oldNames, ", "))
+	}
+
+	getSession(senderID).renameID = storageID
+	userState[senderID] = "renaming_storage"
+	return sendText(senderID, text)
+}
+
+// renameStorage renames a storage in the database and the cache.
+func renameStorage(senderID string, storageID uint, newName string) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if err := database.RenameStorage(senderID, storageID, newName); err != nil {
+		return err
+	}
+	userStorage[senderID][index].StorageName = newName
+	refreshSharedStorage(userStorage[senderID][index])
+	return nil
+}
+
+// handleRenameStorageInput saves the name sent while in the
+// "renaming_storage" state.
+func handleRenameStorageInput(senderID, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return sendText(senderID, tr(senderID, "rename.send_name"))
+	}
+
+	sess := getSession(senderID)
+	if err := renameStorageTo(senderID, sess.renameID, newName); err != nil || userState[senderID] == "renaming_storage" {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
+// renameStorageTo renames a storage and tells the user how it went. The user
+// stays in the "renaming_storage" state when the name is taken, so they can
+// send another.
+func renameStorageTo(senderID string, storageID uint, newName string) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	oldName := userStorage[senderID][index].StorageName
+
+	err := renameStorage(senderID, storageID, newName)
+	if errors.Is(err, database.ErrStorageExists) {
+		return sendText(senderID, tr(senderID, "rename.exists", "storage", newName))
+	}
+	if errors.Is(err, database.ErrMemberHasName) {
+		return sendText(senderID, tr(senderID, "rename.member_has_name", "storage", oldName, "name", newName))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		userState[senderID] = "waiting_for_action"
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "rename.failed"))
+		return err
+	}
+
+	getSession(senderID).renameID = 0
+	userState[senderID] = "waiting_for_action"
+	return sendText(senderID, tr(senderID, "rename.done", "old", oldName, "new", newName))
+}
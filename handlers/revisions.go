@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+const revisionsShown = 10
+
+// handleEntryHistory lists the earlier versions of an entry as cards that can
+// be viewed, compared with another version, or restored.
+func handleEntryHistory(senderID string, contentID uint) error {
+	lockedID, err := lockedEntryStorage(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+
+	revisions, err := database.GetContentRevisions(senderID, contentID, revisionsShown)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return sendText(senderID, tr(senderID, "revision.none"))
+	}
+
+	format := userTimeFormat(senderID)
+	cards := make([]templates.Card, 0, len(revisions))
+	for _, revision := range revisions {
+		cards = append(cards, templates.Card{
+			Title:    tr(senderID, "revision.title", "time", format.Timestamp(revision.Timestamp)),
+			Subtitle: revision.Text(userLocale(senderID)),
+			Buttons: []map[string]string{
+				{"type": "postback", "title": tr(senderID, "revision.view"), "payload": fmt.Sprintf("VIEW_REVISION_%d", revision.ID)},
+				{"type": "postback", "title": tr(senderID, "revision.compare"), "payload": fmt.Sprintf("DIFF_REVISION_%d", revision.ID)},
+				{"type": "postback", "title": tr(senderID, "revision.restore"), "payload": fmt.Sprintf("RESTORE_REVISION_%d", revision.ID)},
+			},
+		})
+	}
+	return services.SendMessage(senderID, templates.CardCarouselTemplate(senderID, cards))
+}
+
+// sendIfRevisionLocked tells the sender when a revision belongs to an entry
+// in a storage they haven't unlocked.
+func sendIfRevisionLocked(senderID string, revision models.ContentRevision) (bool, error) {
+	lockedID, err := lockedEntryStorage(senderID, revision.ContentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, sendEntryNotFound(senderID)
+	}
+	if err != nil || lockedID == 0 {
+		return false, err
+	}
+	return true, sendStorageLocked(senderID, lockedID)
+}
+
+func handleViewRevision(senderID string, revisionID uint) error {
+	revision, err := database.GetContentRevision(senderID, revisionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if locked, err := sendIfRevisionLocked(senderID, revision); locked || err != nil {
+		return err
+	}
+	return sendText(senderID, tr(senderID, "revision.title", "time", userTimeFormat(senderID).Timestamp(revision.Timestamp))+":\n\n"+revision.Text(userLocale(senderID)))
+}
+
+// handleCompareRevision asks which version of the entry to compare a
+// revision with: the current one or another earlier one.
+func handleCompareRevision(senderID string, revisionID uint) error {
+	revision, err := database.GetContentRevision(senderID, revisionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if locked, err := sendIfRevisionLocked(senderID, revision); locked || err != nil {
+		return err
+	}
+	revisions, err := database.GetContentRevisions(senderID, revision.ContentID, revisionsShown)
+	if err != nil {
+		return err
+	}
+
+	format := userTimeFormat(senderID)
+	replies := []map[string]string{
+		{"title": tr(senderID, "revision.current"), "payload": fmt.Sprintf("DIFF_REVISION_%d_TO_0", revision.ID)},
+	}
+	for _, other := range revisions {
+		if other.ID != revision.ID {
+			replies = append(replies, map[string]string{"title": format.Timestamp(other.Timestamp), "payload": fmt.Sprintf("DIFF_REVISION_%d_TO_%d", revision.ID, other.ID)})
+		}
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "revision.compare_prompt", "time", format.Timestamp(revision.Timestamp)), replies))
+}
+
+// handleRevisionDiff shows what changed between two versions of an entry,
+// from the older to the newer; toID 0 is the current version.
+func handleRevisionDiff(senderID string, fromID, toID uint) error {
+	older, newer, err := database.CompareContentRevisions(senderID, fromID, toID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if locked, err := sendIfRevisionLocked(senderID, older); locked || err != nil {
+		return err
+	}
+
+	locale := userLocale(senderID)
+	if older.Text(locale) == newer.Text(locale) {
+		return sendText(senderID, tr(senderID, "revision.same"))
+	}
+	format := userTimeFormat(senderID)
+	heading := tr(senderID, "revision.changes", "from", format.Timestamp(older.Timestamp), "to", format.Timestamp(newer.Timestamp))
+	if toID == 0 {
+		heading = tr(senderID, "revision.changes_to_current", "time", format.Timestamp(older.Timestamp))
+	}
+	return sendText(senderID, heading+"\n\n"+utils.LineDiff(older.Text(locale), newer.Text(locale)))
+}
+
+func handleRestoreRevision(senderID string, revisionID uint) error {
+	revision, err := database.GetContentRevision(senderID, revisionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if locked, err := sendIfRevisionLocked(senderID, revision); locked || err != nil {
+		return err
+	}
+
+	content, err := database.RestoreContentRevision(senderID, revisionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if errors.Is(err, database.ErrMediaEntry) {
+		return sendText(senderID, tr(senderID, "entry.media_not_editable"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "revision.restore_failed"))
+		return err
+	}
+
+	if err := sendText(senderID, tr(senderID, "revision.restored")); err != nil {
+		return err
+	}
+	return handleOpenEntry(senderID, content.ID)
+}
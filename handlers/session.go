@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+)
+
+// session holds per-user conversation state that lives alongside userState.
+// Like userState it is guarded by mu.
+type session struct {
+	// storageIndex is the position in userStorage of the storage the user is
+	// currently adding to or browsing.
+	storageIndex int
+	// cursor is where the next "Show More" page of the open storage starts.
+	cursor database.ContentCursor
+	// entryID is the entry being edited in the "editing_entry" state.
+	entryID uint
+	// folderID is the storage whose nested storages the user is browsing;
+	// 0 is the top level.
+	folderID uint
+	// renameID is the storage being renamed in the "renaming_storage" state.
+	renameID uint
+	// reminderStorageID and reminderContentID say what the reminder being set
+	// in the "setting_reminder" state is for; reminderContentID is 0 for a
+	// whole storage.
+	reminderStorageID uint
+	reminderContentID uint
+	// importStorageID is the storage a file sent in the "importing" state
+	// goes into; 0 uses the storages the file names. importPlan is the
+	// import waiting for the user to confirm it.
+	importStorageID uint
+	importPlan      *importPlan
+	// pinStorageID is the storage whose PIN is asked for in the
+	// "entering_pin", "setting_pin" and "confirming_pin" states, and pinAction
+	// what entering it is for. newPINHash is the new PIN awaiting its repeat.
+	pinStorageID uint
+	pinAction    string
+	newPINHash   string
+	// unlockedUntil is when each storage opened with its PIN locks again.
+	// pinFailures counts wrong PINs in a row; after too many, lockedOutUntil
+	// is when the storage takes PINs again.
+	unlockedUntil  map[uint]time.Time
+	pinFailures    map[uint]int
+	lockedOutUntil map[uint]time.Time
+}
+
+var userSession = make(map[string]*session)
+
+func getSession(senderID string) *session {
+	s, exists := userSession[senderID]
+	if !exists {
+		s = &session{
+			unlockedUntil:  map[uint]time.Time{},
+			pinFailures:    map[uint]int{},
+			lockedOutUntil: map[uint]time.Time{},
+		}
+		userSession[senderID] = s
+	}
+	return s
+}
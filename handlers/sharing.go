@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/deeplink"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+// inviteTTL is how long a share invite code can be redeemed.
+const inviteTTL = 7 * 24 * time.Hour
+
+// notice builds a message for one recipient in their language; name is who
+// made the change.
+type notice func(locale, name string) string
+
+// canWrite reports whether the sender may change a storage: their own, or one
+// shared with them as a contributor.
+func canWrite(senderID string, storageID uint) bool {
+	if index := storageIndexByID(senderID, storageID); index >= 0 && userStorage[senderID][index].SenderID == senderID {
+		return true
+	}
+	role, err := database.StorageRole(senderID, storageID)
+	if err != nil {
+		log.Printf("Failed to look up role on storage %d for senderID %s: %v", storageID, senderID, err)
+		return false
+	}
+	return role == database.RoleOwner || role == models.ShareRoleContributor
+}
+
+// sendReadOnly is the reply when a viewer tries to change a shared storage.
+func sendReadOnly(senderID string) error {
+	userState[senderID] = "waiting_for_action"
+	return sendText(senderID, tr(senderID, "share.read_only"))
+}
+
+// handleShareStorage offers to invite someone to one of the sender's
+// storages, or to see who it is already shared with.
+func handleShareStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 || userStorage[senderID][index].SenderID != senderID {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storageName := userStorage[senderID][index].StorageName
+
+	shares, err := database.GetStorageShares(senderID, storageID)
+	if err != nil {
+		return err
+	}
+	text := tr(senderID, "share.prompt", "storage", storageName)
+	if len(shares) > 0 {
+		text += "\n\n" + trn(senderID, "share.member_count", len(shares))
+	}
+	replies := []map[string]string{
+		{"title": tr(senderID, "share.invite_viewer"), "payload": fmt.Sprintf("SHARE_INVITE_%d_%s", storageID, models.ShareRoleViewer)},
+		{"title": tr(senderID, "share.invite_contributor"), "payload": fmt.Sprintf("SHARE_INVITE_%d_%s", storageID, models.ShareRoleContributor)},
+	}
+	if len(shares) > 0 {
+		replies = append(replies, map[string]string{"title": tr(senderID, "share.people"), "payload": fmt.Sprintf("SHARE_MEMBERS_%d", storageID)})
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, replies))
+}
+
+// handleCreateInvite makes an invite code for a storage and sends it on its
+// own, with an m.me link that redeems it, ready to be forwarded.
+func handleCreateInvite(senderID string, storageID uint, role string) error {
+	if role != models.ShareRoleViewer && role != models.ShareRoleContributor {
+		return sendText(senderID, tr(senderID, "menu.invalid_selection"))
+	}
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storageName := userStorage[senderID][index].StorageName
+
+	invite, err := database.CreateShareInvite(senderID, storageID, role, inviteTTL)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "share.failed"))
+		return err
+	}
+	log.Printf("Created %s invite for storage %d of senderID %s", role, storageID, senderID)
+
+	text := tr(senderID, "share.invite_created", "storage", storageName, "role", tr(senderID, "share.role."+role), "days", int(inviteTTL/(24*time.Hour)))
+	if err := sendText(senderID, text); err != nil {
+		return err
+	}
+	// The code goes on its own so it can be forwarded as is.
+	code := commandPrefix + "join " + invite.Code
+	if url := linkURL(deeplink.AcceptInvite, invite.Code); url != "" {
+		code += "\n" + url
+	}
+	return sendText(senderID, code)
+}
+
+// handleStorageMembers lists who a storage is shared with, with a quick reply
+// to remove each of them.
+func handleStorageMembers(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storageName := userStorage[senderID][index].StorageName
+
+	shares, err := database.GetStorageShares(senderID, storageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	if len(shares) == 0 {
+		return sendText(senderID, tr(senderID, "share.no_members", "storage", storageName))
+	}
+
+	locale := userLocale(senderID)
+	text := tr(senderID, "share.members_heading", "storage", storageName)
+	replies := []map[string]string{}
+	for i, share := range shares {
+		name := displayName(share.SenderID, locale)
+		text += "\n" + tr(senderID, "share.member", "number", i+1, "name", name, "role", tr(senderID, "share.role."+share.Role))
+		replies = append(replies, map[string]string{"title": tr(senderID, "share.remove_member", "name", name), "payload": fmt.Sprintf("REVOKE_SHARE_%d", share.ID)})
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, text, replies))
+}
+
+// handleRevokeShare takes a storage away from someone it was shared with and
+// tells them.
+func handleRevokeShare(senderID string, shareID uint) error {
+	share, err := database.RevokeShare(senderID, shareID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "share.not_found"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "share.failed"))
+		return err
+	}
+	storageName := ""
+	if index := storageIndexByID(senderID, share.StorageContentID); index >= 0 {
+		storageName = userStorage[senderID][index].StorageName
+	}
+	removeCachedStorages(share.SenderID, func(storage models.StorageContent) bool {
+		return storage.ID == share.StorageContentID
+	})
+	log.Printf("Revoked share %d of storage %d for senderID %s", share.ID, share.StorageContentID, senderID)
+
+	go notifyUser(share.SenderID, senderID, func(locale, name string) string {
+		return i18n.T(locale, "share.access_revoked", "name", name, "storage", storageName)
+	})
+	return sendText(senderID, tr(senderID, "share.revoked", "name", displayName(share.SenderID, userLocale(senderID)), "storage", storageName))
+}
+
+// handleLeaveShare gives up the sender's access to a storage shared with
+// them and tells its owner.
+func handleLeaveShare(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 || userStorage[senderID][index].SenderID == senderID {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+
+	err := database.LeaveShare(senderID, storageID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		sendText(senderID, tr(senderID, "share.failed"))
+		return err
+	}
+	removeCachedStorages(senderID, func(cached models.StorageContent) bool {
+		return cached.ID == storageID
+	})
+	if err == nil {
+		go notifyUser(storage.SenderID, senderID, func(locale, name string) string {
+			return i18n.T(locale, "share.member_left", "name", name, "storage", storage.StorageName)
+		})
+	}
+
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "share.left", "storage", storage.StorageName)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+}
+
+// handleJoin redeems an invite code, adds the shared storage to the sender's
+// storages and opens it.
+func handleJoin(senderID, code string) error {
+	storage, share, err := database.RedeemShareInvite(senderID, code)
+	switch {
+	case errors.Is(err, database.ErrInvalidInvite):
+		return sendText(senderID, tr(senderID, "share.invalid_invite"))
+	case errors.Is(err, database.ErrOwnStorage):
+		return sendText(senderID, tr(senderID, "share.own_storage", "storage", storage.StorageName))
+	case errors.Is(err, database.ErrStorageExists):
+		return sendText(senderID, tr(senderID, "share.name_taken", "storage", storage.StorageName))
+	case err != nil:
+		sendText(senderID, tr(senderID, "share.failed"))
+		return err
+	}
+
+	index := storageIndexByID(senderID, storage.ID)
+	if index >= 0 {
+		userStorage[senderID][index] = storage
+	} else {
+		userStorage[senderID] = append(userStorage[senderID], storage)
+		index = len(userStorage[senderID]) - 1
+	}
+	log.Printf("SenderID %s joined storage %d as %s", senderID, storage.ID, share.Role)
+
+	go notifyUser(storage.SenderID, senderID, func(locale, name string) string {
+		return i18n.T(locale, "share.member_joined", "name", name, "storage", storage.StorageName, "role", i18n.T(locale, "share.role."+share.Role))
+	})
+	if err := sendText(senderID, tr(senderID, "share.joined_"+share.Role, "storage", storage.StorageName)); err != nil {
+		return err
+	}
+	return handleStorageSelection(senderID, index+1)
+}
+
+// displayName is the first name on a user's profile, or a stand-in in locale
+// when the page can't read it.
+func displayName(senderID, locale string) string {
+	profile, err := services.GetUserProfile(senderID)
+	if err != nil || profile.FirstName == "" {
+		return i18n.T(locale, "share.someone")
+	}
+	return profile.FirstName
+}
+
+// notifyUser sends a recipient a notice about something actorID did. It
+// makes Graph calls, so callers run it outside mu.
+func notifyUser(recipientID, actorID string, message notice) {
+	locale := userLocale(recipientID)
+	if err := sendText(recipientID, message(locale, displayName(actorID, locale))); err != nil {
+		log.Printf("Failed to notify senderID %s: %v", recipientID, err)
+	}
+}
+
+// notifyMembers tells everyone else with access to a storage about a change
+// the sender made to it. It runs in the background; storages that aren't
+// shared have nobody to tell.
+func notifyMembers(senderID string, storageID uint, message notice) {
+	go func() {
+		members, err := database.StorageMembers(storageID)
+		if err != nil {
+			log.Printf("Failed to load members of storage %d: %v", storageID, err)
+			return
+		}
+		for _, member := range members {
+			if member != senderID {
+				notifyUser(member, senderID, message)
+			}
+		}
+	}()
+}
+
+// notifyEntryAdded tells the other members of a shared storage, in their
+// own language, that the sender added an entry to it. What was added is left
+// out when the storage is locked.
+func notifyEntryAdded(senderID, storageName, contentType, data string) {
+	index := findStorageIndex(senderID, storageName)
+	if index < 0 {
+		return
+	}
+	storage := userStorage[senderID][index]
+	notifyMembers(senderID, storage.ID, func(locale, name string) string {
+		if storage.PINHash != "" {
+			return i18n.T(locale, "share.entry_added_locked", "name", name, "storage", storage.StorageName)
+		}
+		return i18n.T(locale, "share.entry_added", "name", name, "storage", storage.StorageName, "data", models.DataText(locale, contentType, data))
+	})
+}
+
+// removeCachedStorages drops the storages matching remove from a user's
+// cache, keeping the storage they have open pointing at the same one. One
+// that was removed is left out of range, so it has to be chosen again.
+func removeCachedStorages(senderID string, remove func(models.StorageContent) bool) {
+	storages := userStorage[senderID]
+	sess, hasSession := userSession[senderID]
+	openIndex := -1
+	if hasSession && sess.storageIndex < len(storages) {
+		openIndex = sess.storageIndex
+	}
+	openRemoved := openIndex >= 0 && remove(storages[openIndex])
+
+	remaining := storages[:0]
+	for i, storage := range storages {
+		if remove(storage) {
+			continue
+		}
+		if i == openIndex {
+			sess.storageIndex = len(remaining)
+		}
+		remaining = append(remaining, storage)
+	}
+	if openRemoved {
+		sess.storageIndex = len(remaining)
+	}
+	userStorage[senderID] = remaining
+}
+
+// forgetSharedStorages drops the owner's storages with the given IDs from
+// the caches of everyone they were shared with.
+func forgetSharedStorages(ownerID string, storageIDs map[uint]bool) {
+	for senderID := range userStorage {
+		if senderID == ownerID {
+			continue
+		}
+		removeCachedStorages(senderID, func(storage models.StorageContent) bool {
+			return storageIDs[storage.ID]
+		})
+	}
+}
+
+// refreshSharedStorage copies a storage's name, mode and PIN into the caches
+// of everyone it is shared with, after its owner changed them.
+func refreshSharedStorage(storage models.StorageContent) {
+	for senderID := range userStorage {
+		if senderID == storage.SenderID {
+			continue
+		}
+		if index := storageIndexByID(senderID, storage.ID); index >= 0 {
+			userStorage[senderID][index].StorageName = storage.StorageName
+			userStorage[senderID][index].Mode = storage.Mode
+			userStorage[senderID][index].PINHash = storage.PINHash
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
+)
+
+const tagFilterPrefix = "TAG_FILTER_"
+
+// formatTags renders an entry's tags as hashtags, e.g. "#work #urgent".
+func formatTags(tags []models.Tag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = "#" + tag.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// parseTagInput reads tags typed by the user, with or without "#", separated
+// by spaces or commas.
+func parseTagInput(text string) []string {
+	tags := []string{}
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		if tag := utils.NormalizeTag(field); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func handleTagEntry(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+	if !canWrite(senderID, content.StorageContentID) {
+		return sendReadOnly(senderID)
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return sendStorageLocked(senderID, content.StorageContentID)
+	}
+
+	getSession(senderID).entryID = contentID
+	userState[senderID] = "tagging_entry"
+	return sendText(senderID, tr(senderID, "tags.prompt"))
+}
+
+// handleTagEntryInput adds the tags sent while in the "tagging_entry" state.
+func handleTagEntryInput(senderID, text string) error {
+	tags := parseTagInput(text)
+	if len(tags) == 0 {
+		return sendText(senderID, tr(senderID, "tags.need_one"))
+	}
+
+	sess := getSession(senderID)
+	err := database.AddTags(senderID, sess.entryID, tags)
+	sess.entryID = 0
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "tags.add_failed"))
+		return err
+	}
+
+	userState[senderID] = "waiting_for_action"
+	return sendText(senderID, tr(senderID, "tags.added", "tags", "#"+strings.Join(tags, " #")))
+}
+
+// handleBrowseTags lists the sender's tags as quick replies.
+func handleBrowseTags(senderID string) error {
+	tags, err := database.GetTags(senderID)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return sendText(senderID, tr(senderID, "tags.none"))
+	}
+
+	replies := make([]map[string]string, 0, len(tags))
+	for _, tag := range tags {
+		replies = append(replies, map[string]string{
+			"title":   fmt.Sprintf("#%s (%d)", tag.Name, tag.Count),
+			"payload": tagFilterPrefix + tag.Name,
+		})
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "tags.pick"), replies))
+}
+
+// handleTagFilter shows every entry with a tag, whichever storage it is in.
+func handleTagFilter(senderID, tag string) error {
+	results, err := database.GetDataByTag(senderID, tag)
+	if err != nil {
+		sendText(senderID, tr(senderID, "tags.load_failed"))
+		return err
+	}
+	results, hidden := withoutLocked(senderID, results)
+	if len(results) == 0 {
+		return sendText(senderID, strings.TrimSpace(tr(senderID, "tags.nothing", "tag", utils.NormalizeTag(tag))+"\n"+hidden))
+	}
+	text := formatResults(trn(senderID, "tags.heading", len(results), "tag", utils.NormalizeTag(tag)), results, userTimeFormat(senderID))
+	if hidden != "" {
+		text += "\n" + hidden
+	}
+	return sendText(senderID, text)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+	"gorm.io/gorm"
+)
+
+// handleEntryTransfer asks which storage an entry should be moved or copied
+// to. Moving offers every storage but the entry's own.
+func handleEntryTransfer(senderID string, contentID uint, action string) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendEntryNotFound(senderID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if isLocked(senderID, content.StorageContentID) {
+		return sendStorageLocked(senderID, content.StorageContentID)
+	}
+	if action == "MOVE" && !canWrite(senderID, content.StorageContentID) {
+		return sendReadOnly(senderID)
+	}
+
+	replies := []map[string]string{}
+	for _, storage := range userStorage[senderID] {
+		if action == "MOVE" && storage.ID == content.StorageContentID {
+			continue
+		}
+		if storage.SenderID != senderID && !canWrite(senderID, storage.ID) {
+			continue
+		}
+		replies = append(replies, map[string]string{"title": storage.StorageName, "payload": fmt.Sprintf("%s_ENTRY_%d_TO_%d", action, contentID, storage.ID)})
+	}
+	if len(replies) == 0 {
+		return sendText(senderID, tr(senderID, "transfer.nowhere"))
+	}
+	if action == "MOVE" {
+		return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "transfer.move_prompt"), replies))
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "transfer.copy_prompt"), replies))
+}
+
+func handleMoveEntryTo(senderID string, contentID, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	lockedID, err := lockedEntryStorage(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	if lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+
+	err = database.MoveContent(senderID, contentID, storageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "transfer.move_failed"))
+		return err
+	}
+	return sendText(senderID, tr(senderID, "transfer.moved", "storage", userStorage[senderID][index].StorageName))
+}
+
+func handleCopyEntryTo(senderID string, contentID, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	lockedID, err := lockedEntryStorage(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	if err != nil {
+		return err
+	}
+	if lockedID != 0 {
+		return sendStorageLocked(senderID, lockedID)
+	}
+
+	err = database.CopyContent(senderID, contentID, storageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "transfer.not_found"))
+	}
+	if errors.Is(err, database.ErrReadOnly) {
+		return sendReadOnly(senderID)
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "transfer.copy_failed"))
+		return err
+	}
+	return sendText(senderID, tr(senderID, "transfer.copied", "storage", userStorage[senderID][index].StorageName))
+}
+
+// handleMergeStorage asks which of the sender's own storages to merge a
+// storage into, leaving out the storages nested inside it.
+func handleMergeStorage(senderID string, storageID uint) error {
+	index := storageIndexByID(senderID, storageID)
+	if index < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	storage := userStorage[senderID][index]
+	if isLocked(senderID, storageID) {
+		return sendStorageLocked(senderID, storageID)
+	}
+
+	replies := []map[string]string{}
+	for _, target := range userStorage[senderID] {
+		if target.SenderID != senderID || target.ID == storageID || isInside(senderID, target.ID, storageID) {
+			continue
+		}
+		replies = append(replies, map[string]string{"title": target.StorageName, "payload": fmt.Sprintf("MERGE_STORAGE_%d_INTO_%d", storageID, target.ID)})
+	}
+	if len(replies) == 0 {
+		return sendText(senderID, tr(senderID, "merge.nowhere", "storage", storage.StorageName))
+	}
+	return services.SendMessage(senderID, templates.QuickReplyMessage(senderID, tr(senderID, "merge.prompt", "storage", storage.StorageName), replies))
+}
+
+// mergeStorage merges two storages in the database and the cache.
+func mergeStorage(senderID string, sourceID, targetID uint) error {
+	if err := database.MergeStorage(senderID, sourceID, targetID); err != nil {
+		return err
+	}
+
+	remaining := userStorage[senderID][:0]
+	for _, storage := range userStorage[senderID] {
+		if storage.ID == sourceID {
+			continue
+		}
+		if storage.ParentID != nil && *storage.ParentID == sourceID {
+			parentID := targetID
+			storage.ParentID = &parentID
+		}
+		remaining = append(remaining, storage)
+	}
+	userStorage[senderID] = remaining
+	forgetSharedStorages(senderID, map[uint]bool{sourceID: true})
+
+	sess := getSession(senderID)
+	sess.storageIndex = storageIndexByID(senderID, targetID)
+	if sess.folderID == sourceID {
+		sess.folderID = 0
+	}
+	return nil
+}
+
+func handleMergeStorageInto(senderID string, sourceID, targetID uint) error {
+	sourceIndex, targetIndex := storageIndexByID(senderID, sourceID), storageIndexByID(senderID, targetID)
+	if sourceIndex < 0 || targetIndex < 0 {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if isLocked(senderID, sourceID) {
+		return sendStorageLocked(senderID, sourceID)
+	}
+	sourceName, targetName := userStorage[senderID][sourceIndex].StorageName, userStorage[senderID][targetIndex].StorageName
+
+	err := mergeStorage(senderID, sourceID, targetID)
+	if errors.Is(err, database.ErrInvalidMove) {
+		return sendText(senderID, tr(senderID, "merge.into_own_folder"))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sendText(senderID, tr(senderID, "storage.not_found"))
+	}
+	if err != nil {
+		sendText(senderID, tr(senderID, "merge.failed"))
+		return err
+	}
+
+	userState[senderID] = "waiting_for_action"
+	if err := sendText(senderID, tr(senderID, "merge.done", "source", sourceName, "target", targetName)); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/database"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/models"
+	"github.com/markDoesany/quickymessenger/services"
+	"github.com/markDoesany/quickymessenger/templates"
+)
+
+// userLoadedAt is when each sender's storages were loaded into the cache, so
+// data deleted from outside the bot since then can be noticed.
+var userLoadedAt = make(map[string]time.Time)
+
+// handleMyData lists everything stored about the sender, with buttons to
+// export it or delete it all.
+func handleMyData(senderID string) error {
+	summary, err := database.GetDataSummary(senderID)
+	if err != nil {
+		sendText(senderID, tr(senderID, "data.summary_failed"))
+		return err
+	}
+
+	lines := []string{tr(senderID, "data.heading")}
+	lines = append(lines, trn(senderID, "data.storages", len(summary.Storages)))
+	for _, storage := range summary.Storages {
+		lines = append(lines, trn(senderID, "data.storage_entries", storage.Entries, "storage", storage.Name))
+	}
+	lines = append(lines,
+		trn(senderID, "data.entries", summary.Entries),
+		trn(senderID, "data.files", summary.Files, "size", formatBytes(summary.FileBytes)),
+		trn(senderID, "data.revisions", summary.Revisions),
+		trn(senderID, "data.tags", summary.Tags),
+		trn(senderID, "data.reminders", summary.Reminders),
+		trn(senderID, "data.renames", summary.Renames),
+		trn(senderID, "data.shares", summary.Shares),
+	)
+	if summary.Preferences {
+		lines = append(lines, tr(senderID, "data.preferences"))
+	}
+	lines = append(lines, tr(senderID, "data.session"), tr(senderID, "data.logs"))
+
+	// The list can outgrow a button template, so it goes first as text.
+	if err := sendText(senderID, strings.Join(lines, "\n")); err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateMyData(senderID, userLocale(senderID), tr(senderID, "data.actions")))
+}
+
+// handleDeleteAllData asks the sender to confirm deleting everything.
+func handleDeleteAllData(senderID string) error {
+	return services.SendMessage(senderID, templates.ButtonTemplateConfirmDeleteAll(senderID, userLocale(senderID), tr(senderID, "data.delete_confirm")))
+}
+
+func handleConfirmDeleteAllData(senderID string) error {
+	locale := userLocale(senderID)
+	summary, err := deleteUserData(senderID, models.DeletionByUser)
+	if err != nil {
+		sendText(senderID, i18n.T(locale, "data.delete_failed"))
+		return err
+	}
+	return sendText(senderID, i18n.N(locale, "data.deleted", summary.Entries))
+}
+
+// DeleteUserData deletes everything stored about a sender on behalf of
+// source, such as an admin or Facebook's data deletion callback, and forgets
+// them in the bot.
+func DeleteUserData(senderID, source string) (database.DataSummary, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return deleteUserData(senderID, source)
+}
+
+func deleteUserData(senderID, source string) (database.DataSummary, error) {
+	storages, err := database.GetStorages(senderID)
+	if err != nil {
+		return database.DataSummary{}, err
+	}
+	summary, err := database.DeleteUserData(senderID, source)
+	if err != nil {
+		return summary, err
+	}
+	owned := make(map[uint]bool, len(storages))
+	for _, storage := range storages {
+		owned[storage.ID] = true
+	}
+	forgetSharedStorages(senderID, owned)
+	forgetUser(senderID)
+	log.Printf("Deleted all data of senderID %s (%s): %d storages, %d entries, %d files", senderID, source, len(summary.Storages), summary.Entries, summary.Files)
+	return summary, nil
+}
+
+// forgetUser drops everything the bot holds in memory about a sender, so
+// their next message starts over as a new user.
+func forgetUser(senderID string) {
+	delete(userState, senderID)
+	delete(userStorage, senderID)
+	delete(userSession, senderID)
+	delete(userLoadedAt, senderID)
+	forgetPreferences(senderID)
+	forgetProfile(senderID)
+}
+
+// forgetIfDeleted forgets a sender whose data was deleted outside the bot,
+// such as with the admin tool, since their storages were loaded.
+func forgetIfDeleted(senderID string) {
+	loadedAt, exists := userLoadedAt[senderID]
+	if !exists {
+		return
+	}
+	deleted, err := database.DeletedSince(senderID, loadedAt)
+	if err != nil {
+		log.Printf("Failed to check deletions for senderID %s: %v", senderID, err)
+		return
+	}
+	if deleted {
+		forgetUser(senderID)
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
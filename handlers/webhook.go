@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -16,13 +18,14 @@ import (
 	"github.com/markDoesany/quickymessenger/services"
 	"github.com/markDoesany/quickymessenger/templates"
 	"github.com/markDoesany/quickymessenger/utils"
+	"gorm.io/gorm"
 )
 
 var userState = make(map[string]string)
 var userStorage = make(map[string][]models.StorageContent)
 var mu sync.Mutex
 
-var storage_index int = 0
+const entriesPerPage = 5
 
 func InitializeUserStorage(senderID string) {
 	var storageContents []models.StorageContent
@@ -32,6 +35,12 @@ func InitializeUserStorage(senderID string) {
 	}
 
 	userStorage[senderID] = append(userStorage[senderID], storageContents...)
+	shared, err := database.GetSharedStorages(senderID)
+	if err != nil {
+		log.Printf("Failed to load storages shared with senderID %s: %v", senderID, err)
+	}
+	userStorage[senderID] = append(userStorage[senderID], shared...)
+	userLoadedAt[senderID] = time.Now()
 	log.Printf("User storage initialized from database for senderID %s", senderID)
 }
 
@@ -74,22 +83,64 @@ func Webhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	senderID := message.Entry[0].Messaging[0].Sender.ID
+	text := message.Entry[0].Messaging[0].Message.Text
+	postback := message.Entry[0].Messaging[0].Postback.Payload
+	quickReply := message.Entry[0].Messaging[0].Message.QuickReply.Payload
+
+	var links map[string]services.LinkMetadata
+	if postback == "" && quickReply == "" {
+		links = prefetchLinkMetadata(text)
+	}
+	profile := profileToSeed(senderID)
 
 	mu.Lock()
 	defer mu.Unlock()
+	fetchedLinks = links
+	defer func() { fetchedLinks = nil }()
+
+	// New users bring an m.me link's referral with Get Started; existing
+	// conversations get it as an event of its own.
+	referral := message.Entry[0].Messaging[0].Referral
+	if referral == nil {
+		referral = message.Entry[0].Messaging[0].Postback.Referral
+	}
 
+	forgetIfDeleted(senderID)
+	seedPreferences(senderID, profile)
 	if _, exists := userState[senderID]; !exists {
 		InitializeUserStorage(senderID)
-		err = services.SendMessage(senderID, templates.ButtonTemplateGetStarted(senderID))
-		if err != nil {
+		// Buttons from before a restart, such as those on reminders, keep
+		// working; anything else starts with Get Started.
+		if !isCommand(text) && postback == "" && quickReply == "" && referral == nil {
+			err = services.SendMessage(senderID, templates.ButtonTemplateGetStarted(senderID, userLocale(senderID)))
+			if err != nil {
+				log.Printf("Failed to send message: %v", err)
+			}
+			userState[senderID] = "waiting_for_get_started"
+			return
+		}
+		userState[senderID] = "waiting_for_action"
+	}
+
+	if referral != nil {
+		if err := handleReferral(senderID, referral.Ref); err != nil {
 			log.Printf("Failed to send message: %v", err)
 		}
-		userState[senderID] = "waiting_for_get_started"
 		return
 	}
 
-	if message.Entry[0].Messaging[0].Postback.Payload != "" {
-		handlePostbackPayload(senderID, message.Entry[0].Messaging[0].Postback.Payload)
+	if postback != "" {
+		handlePostbackPayload(senderID, postback)
+		return
+	}
+
+	if quickReply != "" {
+		handlePostbackPayload(senderID, quickReply)
+		return
+	}
+
+	if isCommand(text) {
+		handleCommand(senderID, text)
 		return
 	}
 
@@ -101,62 +152,244 @@ func handlePostbackPayload(senderID, payload string) {
 	switch {
 	case payload == "GET_STARTED_PAYLOAD":
 		userState[senderID] = "waiting_for_action"
-		err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
+		err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
 	case payload == "SEARCH_STORAGE_PAYLOAD":
 		log.Printf("Handling SEARCH_STORAGE_PAYLOAD for senderID: %s", senderID)
 		userState[senderID] = "searching"
-		storages := getUserStorages(senderID)
-		if len(storages) == 0 {
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "No storages found."))
-			if err != nil {
-				log.Fatal(err)
+		getSession(senderID).folderID = 0
+		err = sendStorageBrowser(senderID, 0)
+	case strings.HasPrefix(payload, "STORAGE_PAGE_"):
+		// Handle carousel pagination; checked before STORAGE_ which shares its prefix
+		pageIndexStr := strings.TrimPrefix(payload, "STORAGE_PAGE_")
+		pageIndex, parseErr := strconv.Atoi(pageIndexStr)
+		if parseErr != nil {
+			log.Printf("Invalid page index: %s", pageIndexStr)
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "browse.invalid_page")))
+			break
+		}
+		err = sendStorageBrowser(senderID, pageIndex)
+	case strings.HasPrefix(payload, openFolderPrefix):
+		if folderID, ok := parseIDPayload(payload, openFolderPrefix); ok {
+			err = handleOpenFolder(senderID, folderID)
+		}
+	case payload == "FOLDER_UP_PAYLOAD":
+		err = handleFolderUp(senderID)
+	case payload == "STORAGE_OPTIONS_PAYLOAD":
+		err = handleStorageOptions(senderID)
+	case strings.HasPrefix(payload, "MOVE_STORAGE_"):
+		var storageID, folderID uint
+		if _, scanErr := fmt.Sscanf(payload, "MOVE_STORAGE_%d_TO_%d", &storageID, &folderID); scanErr == nil {
+			err = handleMoveStorageTo(senderID, storageID, folderID)
+		} else if storageID, ok := parseIDPayload(payload, "MOVE_STORAGE_"); ok {
+			err = handleMoveStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "MERGE_STORAGE_"):
+		var sourceID, targetID uint
+		if _, scanErr := fmt.Sscanf(payload, "MERGE_STORAGE_%d_INTO_%d", &sourceID, &targetID); scanErr == nil {
+			err = handleMergeStorageInto(senderID, sourceID, targetID)
+		} else if storageID, ok := parseIDPayload(payload, "MERGE_STORAGE_"); ok {
+			err = handleMergeStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "MOVE_ENTRY_"), strings.HasPrefix(payload, "COPY_ENTRY_"):
+		action := payload[:4]
+		var contentID, storageID uint
+		if _, scanErr := fmt.Sscanf(payload[4:], "_ENTRY_%d_TO_%d", &contentID, &storageID); scanErr == nil {
+			if action == "MOVE" {
+				err = handleMoveEntryTo(senderID, contentID, storageID)
+			} else {
+				err = handleCopyEntryTo(senderID, contentID, storageID)
 			}
-			userState[senderID] = "waiting_for_action"
-			err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
-			return
+		} else if contentID, ok := parseIDPayload(payload, action+"_ENTRY_"); ok {
+			err = handleEntryTransfer(senderID, contentID, action)
 		}
-		err = services.SendMessage(senderID, services.ListStoragesMessage(senderID, storages))
-		if err != nil {
-			log.Fatal(err)
+	case strings.HasPrefix(payload, "STORAGE_MODE_"):
+		if storageID, ok := parseIDPayload(payload, "STORAGE_MODE_"); ok {
+			err = handleToggleStorageMode(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, toggleItemPrefix):
+		if contentID, ok := parseIDPayload(payload, toggleItemPrefix); ok {
+			err = handleToggleItem(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "CLEAR_COMPLETED_"):
+		if storageID, ok := parseIDPayload(payload, "CLEAR_COMPLETED_"); ok {
+			err = handleClearCompleted(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "UNCHECK_ALL_"):
+		if storageID, ok := parseIDPayload(payload, "UNCHECK_ALL_"); ok {
+			err = handleUncheckAll(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "REMIND_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "REMIND_ENTRY_"); ok {
+			err = handleRemindEntry(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "REMIND_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "REMIND_STORAGE_"); ok {
+			err = handleRemindStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "SNOOZE_REMINDER_"):
+		var reminderID uint
+		var minutes int
+		if _, scanErr := fmt.Sscanf(payload, "SNOOZE_REMINDER_%d_%d", &reminderID, &minutes); scanErr == nil && minutes > 0 {
+			err = handleSnoozeReminder(senderID, reminderID, minutes)
+		}
+	case strings.HasPrefix(payload, "DISMISS_REMINDER_"):
+		if reminderID, ok := parseIDPayload(payload, "DISMISS_REMINDER_"); ok {
+			err = handleDismissReminder(senderID, reminderID)
+		}
+	case payload == "EXPORT_ALL_PAYLOAD":
+		err = handleExport(senderID, 0)
+	case strings.HasPrefix(payload, "EXPORT_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "EXPORT_STORAGE_"); ok {
+			err = handleExport(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "EXPORT_"):
+		var storageID uint
+		var format string
+		if _, scanErr := fmt.Sscanf(payload, "EXPORT_%d_%s", &storageID, &format); scanErr == nil {
+			err = startExport(senderID, storageID, format)
+		}
+	case payload == "MY_DATA_PAYLOAD":
+		err = handleMyData(senderID)
+	case payload == "DELETE_ALL_DATA_PAYLOAD":
+		err = handleDeleteAllData(senderID)
+	case payload == "CONFIRM_DELETE_ALL_DATA_PAYLOAD":
+		err = handleConfirmDeleteAllData(senderID)
+	case payload == "IMPORT_PAYLOAD":
+		err = handleImport(senderID, 0)
+	case strings.HasPrefix(payload, "IMPORT_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "IMPORT_STORAGE_"); ok {
+			err = handleImport(senderID, storageID)
+		}
+	case payload == "CONFIRM_IMPORT_PAYLOAD":
+		err = handleConfirmImport(senderID)
+	case payload == "CANCEL_IMPORT_PAYLOAD":
+		err = handleCancelImport(senderID)
+	case strings.HasPrefix(payload, "SHARE_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "SHARE_STORAGE_"); ok {
+			err = handleShareStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "SHARE_INVITE_"):
+		var storageID uint
+		var role string
+		if _, scanErr := fmt.Sscanf(payload, "SHARE_INVITE_%d_%s", &storageID, &role); scanErr == nil {
+			err = handleCreateInvite(senderID, storageID, role)
+		}
+	case strings.HasPrefix(payload, "SHARE_MEMBERS_"):
+		if storageID, ok := parseIDPayload(payload, "SHARE_MEMBERS_"); ok {
+			err = handleStorageMembers(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "REVOKE_SHARE_"):
+		if shareID, ok := parseIDPayload(payload, "REVOKE_SHARE_"); ok {
+			err = handleRevokeShare(senderID, shareID)
+		}
+	case strings.HasPrefix(payload, "LEAVE_SHARE_"):
+		if storageID, ok := parseIDPayload(payload, "LEAVE_SHARE_"); ok {
+			err = handleLeaveShare(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "SET_PIN_"):
+		if storageID, ok := parseIDPayload(payload, "SET_PIN_"); ok {
+			err = handleSetPIN(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "REMOVE_PIN_"):
+		if storageID, ok := parseIDPayload(payload, "REMOVE_PIN_"); ok {
+			err = handleRemovePIN(senderID, storageID)
+		}
+	case payload == "CANCEL_PIN_PAYLOAD":
+		err = handleCancelPIN(senderID)
+	case strings.HasPrefix(payload, "RENAME_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "RENAME_STORAGE_"); ok {
+			err = handleRenameStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "REMOVE_STORAGE_ID_"):
+		if storageID, ok := parseIDPayload(payload, "REMOVE_STORAGE_ID_"); ok {
+			err = confirmRemoveStorage(senderID, storageID)
+		}
+	case strings.HasPrefix(payload, "CONFIRM_REMOVE_STORAGE_"):
+		if storageID, ok := parseIDPayload(payload, "CONFIRM_REMOVE_STORAGE_"); ok {
+			err = handleConfirmRemoveStorage(senderID, storageID)
 		}
 	case strings.HasPrefix(payload, "STORAGE_"):
 		// Handle storage selection from carousel or button template
 		storageIndexStr := strings.TrimPrefix(payload, "STORAGE_")
 		if storageIndexStr == "" {
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Invalid storage selection."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.invalid_selection")))
 			break
 		}
 		index, err := strconv.Atoi(storageIndexStr)
 		if err != nil {
 			log.Printf("Invalid storage index: %s", storageIndexStr)
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Invalid storage selection."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.invalid_selection")))
 			break
 		}
 		handleStorageSelection(senderID, index)
-	case strings.HasPrefix(payload, "STORAGE_PAGE_"):
-		// Handle carousel pagination
-		pageIndexStr := strings.TrimPrefix(payload, "STORAGE_PAGE_")
-		pageIndex, err := strconv.Atoi(pageIndexStr)
-		if err != nil {
-			log.Printf("Invalid page index: %s", pageIndexStr)
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Invalid page."))
-			break
-		}
-		storages := getUserStorages(senderID)
-		err = services.SendMessage(senderID, templates.StorageCarouselTemplate(senderID, storages, pageIndex))
 	case payload == "CREATE_STORAGE_PAYLOAD":
 		userState[senderID] = "creating"
-		err = services.SendMessage(senderID, services.TextMessage(senderID, "Please enter the storage name:"))
+		err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.enter_name")))
 	case payload == "REMOVE_STORAGE_PAYLOAD":
 		userState[senderID] = "removing"
 		storages := getUserStorages(senderID)
-		err = services.SendMessage(senderID, services.RemoveListStoragesMessage(senderID, storages))
+		err = services.SendMessage(senderID, services.RemoveListStoragesMessage(senderID, userLocale(senderID), storages))
 	case payload == "ADD_DATA_PAYLOAD":
 		userState[senderID] = "waiting_for_data"
-		err = services.SendMessage(senderID, services.TextMessage(senderID, "Please send a text message (image not yet supported)."))
+		err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "data.prompt")))
+	case payload == "HELP_PAYLOAD":
+		err = handleHelp(senderID)
+	case strings.HasPrefix(payload, helpTopicPrefix):
+		err = handleHelpTopic(senderID, strings.TrimPrefix(payload, helpTopicPrefix))
+	case strings.HasPrefix(payload, helpMorePrefix):
+		err = handleHelpMore(senderID, strings.TrimPrefix(payload, helpMorePrefix))
+	case strings.HasPrefix(payload, openEntryPrefix):
+		if contentID, ok := parseIDPayload(payload, openEntryPrefix); ok {
+			err = handleOpenEntry(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "EDIT_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "EDIT_ENTRY_"); ok {
+			err = handleEditEntry(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "DELETE_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "DELETE_ENTRY_"); ok {
+			err = handleDeleteEntry(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "CONFIRM_DELETE_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "CONFIRM_DELETE_ENTRY_"); ok {
+			err = handleConfirmDeleteEntry(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "MORE_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "MORE_ENTRY_"); ok {
+			err = services.SendMessage(senderID, templates.EntryMoreActionsMessage(senderID, userLocale(senderID), contentID))
+		}
+	case strings.HasPrefix(payload, "TAG_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "TAG_ENTRY_"); ok {
+			err = handleTagEntry(senderID, contentID)
+		}
+	case payload == "BROWSE_TAGS_PAYLOAD":
+		err = handleBrowseTags(senderID)
+	case strings.HasPrefix(payload, tagFilterPrefix):
+		err = handleTagFilter(senderID, strings.TrimPrefix(payload, tagFilterPrefix))
+	case strings.HasPrefix(payload, "HISTORY_ENTRY_"):
+		if contentID, ok := parseIDPayload(payload, "HISTORY_ENTRY_"); ok {
+			err = handleEntryHistory(senderID, contentID)
+		}
+	case strings.HasPrefix(payload, "VIEW_REVISION_"):
+		if revisionID, ok := parseIDPayload(payload, "VIEW_REVISION_"); ok {
+			err = handleViewRevision(senderID, revisionID)
+		}
+	case strings.HasPrefix(payload, "DIFF_REVISION_"):
+		var fromID, toID uint
+		if _, scanErr := fmt.Sscanf(payload, "DIFF_REVISION_%d_TO_%d", &fromID, &toID); scanErr == nil {
+			err = handleRevisionDiff(senderID, fromID, toID)
+		} else if revisionID, ok := parseIDPayload(payload, "DIFF_REVISION_"); ok {
+			err = handleCompareRevision(senderID, revisionID)
+		}
+	case strings.HasPrefix(payload, "RESTORE_REVISION_"):
+		if revisionID, ok := parseIDPayload(payload, "RESTORE_REVISION_"); ok {
+			err = handleRestoreRevision(senderID, revisionID)
+		}
+	case payload == "SHOW_MORE_PAYLOAD":
+		err = showStoragePage(senderID)
 	case payload == "EXIT_PAYLOAD":
 		userState[senderID] = "waiting_for_action"
-		err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
+		err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
 	default:
 		if strings.HasPrefix(payload, "REMOVE_STORAGE_") {
 			storageIndex := strings.TrimPrefix(payload, "REMOVE_STORAGE_")
@@ -166,13 +399,12 @@ func handlePostbackPayload(senderID, payload string) {
 				log.Printf("Invalid storage index: %s", storageIndex)
 				return
 			}
-			storage_index = index - 1
 			handleRemoveStorageSelection(senderID, index)
 		} else {
 			userState[senderID] = "waiting_for_action"
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Invalid selection. Please choose an option."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "menu.invalid_selection")))
 			if err == nil {
-				err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
+				err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
 			}
 		}
 	}
@@ -187,124 +419,278 @@ func handleTextInput(senderID string, message models.Message) {
 	if exists {
 		switch state {
 		case "creating":
-			storageName := message.Entry[0].Messaging[0].Message.Text
-			log.Printf("Creating storage with name: %s for senderID: %s", storageName, senderID)
-			userStorage[senderID] = append(userStorage[senderID], models.StorageContent{StorageName: storageName, Contents: []models.Content{}})
-			userState[senderID] = "storing_data"
-			storages := getUserStorages(senderID)
-			storage_index = len(storages) - 1
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Storage created: *"+storageName+"*"))
-			if err == nil {
-				err = services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID))
-			}
+			err = handleCreateStorageInput(senderID, message.Entry[0].Messaging[0].Message.Text)
 		case "storing_data":
 			userState[senderID] = "waiting_for_data"
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Please send a text message or an image."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "data.prompt")))
 		case "waiting_for_data":
-			var data string
-			// if len(message.Entry[0].Messaging[0].Message.Attachments) > 0 {
-			// 	attachment := message.Entry[0].Messaging[0].Message.Attachments[0]
-			// 	if attachment.Type == "image" {
-			// 		data = attachment.Payload.URL
-			// 		log.Printf("Received image URL: %s", data)
-			// 	} else {
-			// 		log.Printf("Unsupported attachment type: %s", attachment.Type)
-			// 		err = services.SendMessage(senderID, services.TextMessage(senderID, "Unsupported attachment type. Please send an image."))
-			// 		break
-			// 	}
-			// } else {
-			// }
-			data = message.Entry[0].Messaging[0].Message.Text
+			storageIndex := getSession(senderID).storageIndex
+			if storageIndex >= len(userStorage[senderID]) {
+				userState[senderID] = "waiting_for_action"
+				err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+				break
+			}
+			if attachments := message.Entry[0].Messaging[0].Message.Attachments; len(attachments) > 0 {
+				err = handleAttachmentInput(senderID, userStorage[senderID][storageIndex].StorageName, attachments)
+				break
+			}
+			data := message.Entry[0].Messaging[0].Message.Text
+			if data == "" {
+				err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "data.prompt")))
+				break
+			}
 			timestamp := time.Now()
-			log.Printf("Storing data: %s with timestamp: %s for senderID: %s", data, timestamp, senderID)
-			err = database.StoreDataInDB(senderID, userStorage[senderID][storage_index].StorageName, timestamp, data)
+			log.Printf("Storing data with timestamp: %s for senderID: %s", timestamp, senderID)
+			contentType, typedData, classifyErr := classifyText(data)
+			if classifyErr != nil {
+				err = classifyErr
+				break
+			}
+			storageName := userStorage[senderID][storageIndex].StorageName
+			err = database.StoreTypedDataInDB(senderID, storageName, timestamp, contentType, typedData, utils.ParseHashtags(data)...)
+			if errors.Is(err, database.ErrReadOnly) {
+				err = sendReadOnly(senderID)
+				break
+			}
 			if err != nil {
 				log.Printf("Failed to store data in database: %v", err)
 				break
 			}
+			notifyEntryAdded(senderID, storageName, contentType, typedData)
 			userState[senderID] = "storing_data"
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "Data stored: "+data+"."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "data.stored", "data", models.DataText(userLocale(senderID), contentType, typedData))))
 			if err == nil {
-				err = services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID))
+				err = services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
 			}
+		case "importing":
+			err = handleImportFile(senderID, message.Entry[0].Messaging[0].Message.Attachments)
+		case "entering_pin":
+			err = handlePINInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "setting_pin":
+			err = handleNewPINInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "confirming_pin":
+			err = handleConfirmPINInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "tagging_entry":
+			err = handleTagEntryInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "setting_reminder":
+			err = handleReminderInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "checklist":
+			err = handleChecklistInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "renaming_storage":
+			err = handleRenameStorageInput(senderID, message.Entry[0].Messaging[0].Message.Text)
+		case "editing_entry":
+			err = handleEditEntryInput(senderID, message.Entry[0].Messaging[0].Message.Text)
 		case "searching":
 			storageName := message.Entry[0].Messaging[0].Message.Text
 			log.Printf("Searching storage with name: %s for senderID: %s", storageName, senderID)
 		default:
 			userState[senderID] = "waiting_for_action"
-			err = services.SendMessage(senderID, services.TextMessage(senderID, "I didn't understand that. Click a button to proceed."))
+			err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "menu.not_understood")))
 			if err == nil {
-				err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
+				err = services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
 			}
 		}
 	} else {
-		err = services.SendMessage(senderID, services.TextMessage(senderID, "Click 'Get Started' to begin."))
+		err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "menu.get_started_hint")))
 	}
 	if err != nil {
 		log.Printf("Failed to send message: %v", err)
 	}
 }
 
+// handleCreateStorageInput creates a storage with the name sent in the
+// "creating" state and waits for its first entry.
+func handleCreateStorageInput(senderID, storageName string) error {
+	log.Printf("Creating storage with name: %s for senderID: %s", storageName, senderID)
+	err := createStorage(senderID, storageName)
+	if errors.Is(err, database.ErrStorageExists) {
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.exists_enter_another", "storage", storageName)))
+	}
+	if err != nil {
+		log.Printf("Failed to create storage: %v", err)
+		return err
+	}
+	userState[senderID] = "storing_data"
+	storages := getUserStorages(senderID)
+	getSession(senderID).storageIndex = len(storages) - 1
+	err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.created", "storage", storageName)))
+	if err != nil {
+		return err
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
 func handleStorageSelection(senderID string, index int) error {
 	storages, exists := userStorage[senderID]
 	if !exists || len(storages) == 0 {
 		log.Printf("No storages found for senderID: %s", senderID)
-		return services.SendMessage(senderID, services.TextMessage(senderID, "You don't have any storages."))
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.none")))
+	}
+	if index < 1 || index > len(storages) {
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.invalid_selection")))
 	}
 
 	storage := storages[index-1]
+	if isLocked(senderID, storage.ID) {
+		return askPIN(senderID, storage.ID, pinActionOpen)
+	}
 	log.Printf("Retrieving storage: %s for senderID: %s", storage.StorageName, senderID)
 
-	contents, err := database.GetStorageData(senderID, storage.StorageName)
+	if storage.Mode == models.StorageModeChecklist {
+		return sendChecklist(senderID, index-1)
+	}
+
+	sess := getSession(senderID)
+	sess.storageIndex = index - 1
+	sess.cursor = database.ContentCursor{}
+
+	err := services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.name", "storage", storage.StorageName)))
 	if err != nil {
-		log.Printf("Failed to get storage content: %v", err)
+		log.Printf("Failed to send storage content: %v", err)
 		return err
 	}
-	if len(contents) == 0 {
-		services.SendMessage(senderID, services.TextMessage(senderID, "No data found in storage: _"+storage.StorageName+"_"))
+	return showStoragePage(senderID)
+}
+
+// showStoragePage sends the next page of entries of the storage the user has
+// open and offers "Show More" while entries remain.
+func showStoragePage(senderID string) error {
+	sess := getSession(senderID)
+	if sess.storageIndex >= len(userStorage[senderID]) {
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.select_first")))
+	}
+	storage := userStorage[senderID][sess.storageIndex]
+	if isLocked(senderID, storage.ID) {
+		return askPIN(senderID, storage.ID, pinActionOpen)
 	}
 
-	log.Printf("Storage contents for %s: %v", storage.StorageName, contents)
-	err = services.SendMessage(senderID, services.TextMessage(senderID, "Storage Name: "+storage.StorageName))
+	preference, err := database.GetUserPreference(senderID)
 	if err != nil {
-		log.Printf("Failed to send storage content: %v", err)
+		log.Printf("Failed to load preferences for senderID %s: %v", senderID, err)
+	}
+	pageSize := entriesPerPage
+	if preference.ViewMode == models.ViewModeDigest {
+		pageSize = digestEntriesPerPage
+	}
+
+	contents, next, hasMore, err := database.GetStorageDataPage(senderID, storage.StorageName, sess.cursor, pageSize)
+	if err != nil {
+		log.Printf("Failed to get storage content: %v", err)
 		return err
 	}
-	for _, content := range contents {
-		responseMessage := "Timestamp:\n" + utils.FormatTimestamp(content.Timestamp) + "\n\nData:\n" + content.Data
-		err := services.SendMessage(senderID, services.TextMessage(senderID, responseMessage))
+	if len(contents) == 0 && sess.cursor.IsZero() {
+		err = services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.no_data", "storage", storage.StorageName)))
 		if err != nil {
-			log.Printf("Failed to send storage content: %v", err)
 			return err
 		}
 	}
+	sess.cursor = next
+
+	if preference.ViewMode == models.ViewModeDigest {
+		if err := sendDigest(senderID, contents); err != nil {
+			log.Printf("Failed to send storage digest: %v", err)
+			return err
+		}
+	} else {
+		for _, content := range contents {
+			if err := sendContent(senderID, content); err != nil {
+				log.Printf("Failed to send storage content: %v", err)
+				return err
+			}
+		}
+	}
 
 	userState[senderID] = "waiting_for_action"
-	err = services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID))
+	if hasMore {
+		return services.SendMessage(senderID, templates.ButtonTemplateShowMoreOrExit(senderID, userLocale(senderID)))
+	}
+	if !canWrite(senderID, storage.ID) {
+		return services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID, userLocale(senderID)))
+	}
+	return services.SendMessage(senderID, templates.ButtonTemplateAddOrExit(senderID, userLocale(senderID)))
+}
+
+// sendContent sends a single entry in full with buttons to edit or delete it.
+func sendContent(senderID string, content models.Content) error {
+	if content.Attachment != nil {
+		if err := sendAttachment(senderID, *content.Attachment); err != nil {
+			log.Printf("Failed to send attachment %d: %v", content.Attachment.ID, err)
+			sendText(senderID, tr(senderID, "entry.attachment_failed"))
+		}
+	} else if err := sendTypedContent(senderID, content); err != nil {
+		log.Printf("Failed to send %s entry %d: %v", content.Type, content.ID, err)
+	}
+
+	format := userTimeFormat(senderID)
+	text := tr(senderID, "entry.details", "time", format.Timestamp(content.Timestamp), "relative", format.Relative(content.Timestamp, time.Now()), "data", content.Text(userLocale(senderID)))
+	if len(content.Tags) > 0 {
+		text += "\n\n" + tr(senderID, "entry.tags", "tags", formatTags(content.Tags))
+	}
+	// Button templates can't hold long entries, so send those as plain text first.
+	if len([]rune(text)) > 640 {
+		if err := sendText(senderID, text); err != nil {
+			return err
+		}
+		text = tr(senderID, "entry.prompt")
+	}
+	return services.SendMessage(senderID, templates.EntryActionsTemplate(senderID, userLocale(senderID), text, content.ID))
+}
 
-	return err
+// handleOpenEntry shows one entry in full, e.g. from a digest's "Open" button.
+func handleOpenEntry(senderID string, contentID uint) error {
+	content, err := database.GetContent(senderID, contentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "entry.not_found")))
+	}
+	if err != nil {
+		return err
+	}
+	if isLocked(senderID, content.StorageContentID) {
+		return sendStorageLocked(senderID, content.StorageContentID)
+	}
+	return sendContent(senderID, content)
 }
 
 func handleRemoveStorageSelection(senderID string, index int) error {
 	storages, exists := userStorage[senderID]
 	if !exists || len(storages) == 0 {
 		log.Printf("No storages found for senderID: %s", senderID)
-		return services.SendMessage(senderID, services.TextMessage(senderID, "You don't have any storages."))
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.none")))
 	}
 
-	storage_ := storages[index-1]
-	log.Printf("Retrieving storage: %s for senderID: %s", storage_.StorageName, senderID)
+	if index < 1 || index > len(storages) {
+		return services.SendMessage(senderID, services.TextMessage(senderID, tr(senderID, "storage.invalid_selection")))
+	}
+	return confirmRemoveStorage(senderID, storages[index-1].ID)
+}
 
-	for i, storage := range userStorage[senderID] {
-		if storage.StorageName == storage_.StorageName {
-			userStorage[senderID] = append(userStorage[senderID][:i], userStorage[senderID][i+1:]...)
-			break
-		}
+// createStorage persists a new storage and adds it to the user's cache.
+func createStorage(senderID, storageName string) error {
+	storageContent, err := database.CreateStorage(senderID, storageName)
+	if err != nil {
+		return err
 	}
-	userState[senderID] = "waiting_for_action"
-	err := services.SendMessage(senderID, templates.ButtonTemplateMessage(senderID))
+	userStorage[senderID] = append(userStorage[senderID], storageContent)
+	return nil
+}
 
-	return err
+// removeStorage deletes a storage and everything nested in it from the
+// database and the cache.
+func removeStorage(senderID string, storageID uint) error {
+	deletedIDs, err := database.DeleteStorage(senderID, storageID)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[uint]bool, len(deletedIDs))
+	for _, id := range deletedIDs {
+		deleted[id] = true
+	}
+	removeCachedStorages(senderID, func(storage models.StorageContent) bool {
+		return deleted[storage.ID]
+	})
+	forgetSharedStorages(senderID, deleted)
+	return nil
 }
 
 func getUserStorages(senderID string) []string {
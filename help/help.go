@@ -0,0 +1,127 @@
+package help
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Topic is a single help page that users can browse.
+type Topic struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Content is the layout of the help file. States maps a conversation state
+// (see handlers.userState) to the topic shown when the user asks for help
+// while in that state.
+type Content struct {
+	DefaultTopic string            `json:"default_topic"`
+	Topics       []Topic           `json:"topics"`
+	States       map[string]string `json:"states"`
+}
+
+var (
+	// contents holds the help in each language; "" is the default file.
+	contents map[string]Content
+	mu       sync.RWMutex
+)
+
+// Load reads the help content from path, replacing whatever was loaded
+// before. Translations sit next to it with the language before the
+// extension, e.g. help.es.json beside help.json.
+func Load(path string) error {
+	c, err := readContent(path)
+	if err != nil {
+		return err
+	}
+	loaded := map[string]Content{"": c}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	translations, err := filepath.Glob(base + ".*" + ext)
+	if err != nil {
+		return fmt.Errorf("failed to list help translations: %w", err)
+	}
+	for _, translation := range translations {
+		language := strings.TrimSuffix(strings.TrimPrefix(translation, base+"."), ext)
+		c, err := readContent(translation)
+		if err != nil {
+			return err
+		}
+		loaded[language] = c
+	}
+
+	mu.Lock()
+	contents = loaded
+	mu.Unlock()
+	return nil
+}
+
+func readContent(path string) (Content, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Content{}, fmt.Errorf("failed to read help file: %w", err)
+	}
+
+	var c Content
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Content{}, fmt.Errorf("failed to parse help file %s: %w", path, err)
+	}
+	if len(c.Topics) == 0 {
+		return Content{}, fmt.Errorf("help file %s has no topics", path)
+	}
+	if c.DefaultTopic == "" {
+		c.DefaultTopic = c.Topics[0].ID
+	}
+	return c, nil
+}
+
+// in returns the help in a language, or the default help when it has not
+// been translated. The caller must hold mu.
+func in(language string) Content {
+	if c, ok := contents[language]; ok {
+		return c
+	}
+	return contents[""]
+}
+
+// Topics returns every help topic in file order.
+func Topics(language string) []Topic {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Topic(nil), in(language).Topics...)
+}
+
+// Lookup returns the topic with the given id.
+func Lookup(language, id string) (Topic, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return lookup(in(language), id)
+}
+
+func lookup(c Content, id string) (Topic, bool) {
+	for _, topic := range c.Topics {
+		if topic.ID == id {
+			return topic, true
+		}
+	}
+	return Topic{}, false
+}
+
+// ForState returns the topic that best explains the given conversation
+// state, falling back to the default topic.
+func ForState(language, state string) (Topic, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c := in(language)
+	id, ok := c.States[state]
+	if !ok {
+		id = c.DefaultTopic
+	}
+	return lookup(c, id)
+}
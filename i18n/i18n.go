@@ -0,0 +1,264 @@
+// Package i18n holds the bot's replies in every language it speaks. Each
+// language has a catalog file, e.g. locales/es.json, mapping message keys to
+// text. Text may contain {placeholders}; messages that depend on a count give
+// one text per plural form instead:
+//
+//	"entries.count": {"one": "{count} entry", "other": "{count} entries"}
+//
+// Keys starting with "@" hold settings rather than messages; "@messenger"
+// lists the Messenger locales, such as es_ES and es_LA, a catalog serves.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fallback is the language whose catalog every other one is checked against
+// and whose text is used when a translation is missing.
+const Fallback = "en"
+
+// message is the text of one key: either a single text or one per plural
+// form.
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.plural); err != nil {
+		return fmt.Errorf("a message must be a text or an object of plural forms")
+	}
+	if _, ok := m.plural["other"]; !ok {
+		return fmt.Errorf("plural messages need an \"other\" form")
+	}
+	return nil
+}
+
+type catalog struct {
+	messages  map[string]message
+	messenger []string
+}
+
+var (
+	catalogs = map[string]catalog{}
+	mu       sync.RWMutex
+)
+
+// Load reads every *.json catalog in dir, replacing those loaded before. It
+// fails when the fallback catalog is missing. Keys missing from other
+// catalogs are reported in the error, which still leaves everything loaded.
+func Load(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list catalogs: %w", err)
+	}
+
+	loaded := map[string]catalog{}
+	for _, path := range paths {
+		language := strings.TrimSuffix(filepath.Base(path), ".json")
+		c, err := readCatalog(path)
+		if err != nil {
+			return err
+		}
+		loaded[normalize(language)] = c
+	}
+	if _, ok := loaded[Fallback]; !ok {
+		return fmt.Errorf("no %s catalog in %s", Fallback, dir)
+	}
+
+	mu.Lock()
+	catalogs = loaded
+	mu.Unlock()
+	return Validate()
+}
+
+func readCatalog(path string) (catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return catalog{}, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return catalog{}, fmt.Errorf("failed to parse catalog %s: %w", path, err)
+	}
+
+	c := catalog{messages: make(map[string]message, len(raw))}
+	for key, value := range raw {
+		if key == "@messenger" {
+			if err := json.Unmarshal(value, &c.messenger); err != nil {
+				return catalog{}, fmt.Errorf("catalog %s: @messenger must be a list of locales", path)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "@") {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal(value, &m); err != nil {
+			return catalog{}, fmt.Errorf("catalog %s, key %s: %w", path, key, err)
+		}
+		c.messages[key] = m
+	}
+	return c, nil
+}
+
+// Validate reports keys of the fallback catalog that another catalog lacks,
+// and plural messages that are plain text in one catalog but not the other.
+func Validate() error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var problems []string
+	for _, language := range sortedLanguages() {
+		if language == Fallback {
+			continue
+		}
+		for key, want := range catalogs[Fallback].messages {
+			got, ok := catalogs[language].messages[key]
+			switch {
+			case !ok:
+				problems = append(problems, language+": missing "+key)
+			case (want.plural == nil) != (got.plural == nil):
+				problems = append(problems, language+": "+key+" differs in plural forms")
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("incomplete catalogs:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func sortedLanguages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Languages returns the loaded languages, sorted.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedLanguages()
+}
+
+// MessengerLocales returns the Messenger locales a language's catalog
+// serves.
+func MessengerLocales(language string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string(nil), catalogs[normalize(language)].messenger...)
+}
+
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// Match returns the loaded language that best serves a locale such as
+// "es_LA": the locale itself, then its language, then the fallback.
+func Match(locale string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return match(locale)
+}
+
+func match(locale string) string {
+	locale = normalize(locale)
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		if _, ok := catalogs[language]; ok {
+			return language
+		}
+	}
+	return Fallback
+}
+
+func lookup(locale, key string) (message, string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	language := match(locale)
+	if m, ok := catalogs[language].messages[key]; ok {
+		return m, language, true
+	}
+	m, ok := catalogs[Fallback].messages[key]
+	return m, Fallback, ok
+}
+
+// T returns the text of key in the locale's language with its placeholders
+// filled from args, given as name, value pairs:
+//
+//	i18n.T(locale, "storage.created", "storage", storageName)
+//
+// Unknown keys come back as the key itself so they stand out.
+func T(locale, key string, args ...interface{}) string {
+	m, _, ok := lookup(locale, key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		text = m.plural["other"]
+	}
+	return fill(text, args)
+}
+
+// N is T for messages that depend on a count. The count picks the plural
+// form and fills the {count} placeholder.
+func N(locale, key string, count int, args ...interface{}) string {
+	m, language, ok := lookup(locale, key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		text, ok = m.plural[pluralForm(language, count)]
+		if !ok {
+			text = m.plural["other"]
+		}
+	}
+	return fill(text, append([]interface{}{"count", count}, args...))
+}
+
+func fill(text string, args []interface{}) string {
+	if len(args) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// pluralForm picks the CLDR plural category of count for a language. Only
+// the rules of the shipped languages are needed: English and Spanish use
+// "one" for 1, French for 0 and 1.
+func pluralForm(language string, count int) string {
+	base, _, _ := strings.Cut(language, "-")
+	switch base {
+	case "fr", "pt":
+		if count == 0 || count == 1 {
+			return "one"
+		}
+	default:
+		if count == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
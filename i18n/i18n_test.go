@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestShippedCatalogsAreComplete(t *testing.T) {
+	if err := Load("locales"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(Languages()) < 2 {
+		t.Fatalf("loaded %v, want every shipped catalog", Languages())
+	}
+}
+
+// translators are the functions whose key argument is checked, and which
+// argument it is.
+var translators = map[string]int{"tr": 1, "trn": 1, "i18n.T": 1, "i18n.N": 1, "T": 1, "N": 1}
+
+// TestUsedKeysExist looks for literal keys passed to the translation
+// functions anywhere in the module and checks the fallback catalog has them.
+func TestUsedKeysExist(t *testing.T) {
+	if err := Load("locales"); err != nil {
+		t.Fatal(err)
+	}
+
+	found := 0
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != ".." && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			arg, ok := translators[calleeName(call.Fun)]
+			if !ok || len(call.Args) <= arg {
+				return true
+			}
+			literal, ok := call.Args[arg].(*ast.BasicLit)
+			if !ok || literal.Kind != token.STRING {
+				return true
+			}
+			key, err := strconv.Unquote(literal.Value)
+			if err != nil {
+				return true
+			}
+			found++
+			if _, _, ok := lookup(Fallback, key); !ok {
+				t.Errorf("%s: key %q is not in the %s catalog", fset.Position(literal.Pos()), key, Fallback)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == 0 {
+		t.Fatal("found no translated keys; is the module root at ..?")
+	}
+}
+
+func calleeName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := f.X.(*ast.Ident); ok {
+			return pkg.Name + "." + f.Sel.Name
+		}
+	}
+	return ""
+}
+
+func TestN(t *testing.T) {
+	if err := Load("locales"); err != nil {
+		t.Fatal(err)
+	}
+	for _, locale := range Languages() {
+		one, other := N(locale, "pin.wrong", 1), N(locale, "pin.wrong", 3)
+		if one == other || !strings.Contains(one, "1") || !strings.Contains(other, "3") {
+			t.Errorf("%s: N picked %q for 1 and %q for 3", locale, one, other)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package importer reads entries from files users send to the bot: the JSON
+// the export package writes, CSV (the export's columns, or any table whose
+// rows are entries) and plain text with one entry per line.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/markDoesany/quickymessenger/export"
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported file")
+	ErrEmpty       = errors.New("no entries in file")
+)
+
+// Parse reads the storages and entries in a file. Entries of files without
+// storage names, such as plain text, go into one storage named after the
+// file. Entries without a timestamp get now.
+func Parse(fileName, mimeType string, data []byte, now time.Time) ([]export.Storage, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, ErrUnsupported
+	}
+
+	ext := strings.ToLower(path.Ext(fileName))
+	var storages []export.Storage
+	var err error
+	switch {
+	case ext == ".json" || mimeType == "application/json":
+		storages, err = parseJSON(data)
+	case ext == ".csv" || mimeType == "text/csv":
+		storages, err = parseCSV(data, StorageName(fileName))
+	case ext == ".txt" || ext == ".md" || strings.HasPrefix(mimeType, "text/"):
+		storages = parseText(data, StorageName(fileName))
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for i := range storages {
+		for j := range storages[i].Entries {
+			if storages[i].Entries[j].Timestamp.IsZero() {
+				storages[i].Entries[j].Timestamp = now
+			}
+		}
+		count += len(storages[i].Entries)
+	}
+	if count == 0 {
+		return nil, ErrEmpty
+	}
+	return storages, nil
+}
+
+// StorageName is the storage a file's entries go into when it doesn't name
+// one: the file name without its extension.
+func StorageName(fileName string) string {
+	name := strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	if name == "" || name == "." || name == "/" {
+		return "Imported"
+	}
+	return name
+}
+
+func parseJSON(data []byte) ([]export.Storage, error) {
+	var doc export.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return doc.Storages, nil
+}
+
+// parseCSV reads the columns of an export when the first row names them,
+// and otherwise treats each row as the text of one entry.
+func parseCSV(data []byte, storageName string) ([]export.Storage, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	textColumn, hasHeader := columns["text"]
+	if hasHeader {
+		rows = rows[1:]
+	}
+	cell := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && hasHeader && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	byName := map[string]int{}
+	storages := []export.Storage{}
+	for _, row := range rows {
+		var entry export.Entry
+		if hasHeader {
+			if textColumn >= len(row) {
+				continue
+			}
+			entry.Text = row[textColumn]
+			entry.Type = cell(row, "type")
+			entry.Done, _ = strconv.ParseBool(cell(row, "done"))
+			entry.Timestamp, _ = time.Parse(time.RFC3339, cell(row, "timestamp"))
+			for _, tag := range strings.Fields(cell(row, "tags")) {
+				entry.Tags = append(entry.Tags, strings.TrimPrefix(tag, "#"))
+			}
+		} else {
+			cells := []string{}
+			for _, c := range row {
+				if c = strings.TrimSpace(c); c != "" {
+					cells = append(cells, c)
+				}
+			}
+			entry.Text = strings.Join(cells, ", ")
+		}
+		if strings.TrimSpace(entry.Text) == "" {
+			continue
+		}
+		// CSV only keeps the readable text of typed entries.
+		if entry.Type != models.ContentTypeMedia {
+			entry.Type = models.ContentTypeText
+		}
+
+		name := cell(row, "storage")
+		if name == "" {
+			name = storageName
+		}
+		i, ok := byName[strings.ToLower(name)]
+		if !ok {
+			i = len(storages)
+			byName[strings.ToLower(name)] = i
+			storages = append(storages, export.Storage{Name: name, Mode: models.StorageModeNotes})
+		}
+		storages[i].Entries = append(storages[i].Entries, entry)
+	}
+	return storages, nil
+}
+
+func parseText(data []byte, storageName string) []export.Storage {
+	storage := export.Storage{Name: storageName, Mode: models.StorageModeNotes}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			storage.Entries = append(storage.Entries, export.Entry{Type: models.ContentTypeText, Text: line})
+		}
+	}
+	return []export.Storage{storage}
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/export"
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+var now = time.Date(2026, time.March, 18, 14, 30, 0, 0, time.UTC)
+
+func exported() export.Document {
+	at := time.Date(2026, time.March, 1, 9, 15, 0, 0, time.UTC)
+	return export.Document{
+		ExportedAt: now,
+		Storages: []export.Storage{
+			{Name: "Groceries", Mode: models.StorageModeChecklist, Entries: []export.Entry{
+				{ID: 1, Timestamp: at, Type: models.ContentTypeText, Text: "milk", Done: true},
+				{ID: 2, Timestamp: at.Add(time.Hour), Type: models.ContentTypeText, Text: "eggs, a dozen", Tags: []string{"weekly"}},
+			}},
+			{Name: "Work notes", Mode: models.StorageModeNotes, Entries: []export.Entry{
+				{ID: 3, Timestamp: at.Add(2 * time.Hour), Type: models.ContentTypeText, Text: "ship \"v2\"\nthen rest", Tags: []string{"work", "urgent"}},
+			}},
+		},
+	}
+}
+
+// entries keeps what an import carries over from each storage.
+func entries(storages []export.Storage) map[string][]export.Entry {
+	byName := map[string][]export.Entry{}
+	for _, storage := range storages {
+		for _, entry := range storage.Entries {
+			entry.ID = 0
+			entry.Timestamp = entry.Timestamp.UTC()
+			byName[storage.Name] = append(byName[storage.Name], entry)
+		}
+	}
+	return byName
+}
+
+func TestParseExport(t *testing.T) {
+	doc := exported()
+	for _, format := range []string{export.JSON, export.CSV} {
+		data, fileName, mimeType, err := export.Render(doc, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		storages, err := Parse(fileName, mimeType, data, now)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if got, want := entries(storages), entries(doc.Storages); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: imported %+v, want %+v", format, got, want)
+		}
+	}
+}
+
+func TestParseJSONKeepsModes(t *testing.T) {
+	data, fileName, mimeType, err := export.Render(exported(), export.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storages, err := Parse(fileName, mimeType, data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storages) != 2 || storages[0].Mode != models.StorageModeChecklist || storages[1].Mode != models.StorageModeNotes {
+		t.Errorf("imported %+v, want a checklist and notes", storages)
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	data := []byte("milk , 2 litres\n\n,eggs,\n\" \",\n")
+	storages, err := Parse("shopping list.csv", "", data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []export.Storage{{Name: "shopping list", Mode: models.StorageModeNotes, Entries: []export.Entry{
+		{Timestamp: now, Type: models.ContentTypeText, Text: "milk, 2 litres"},
+		{Timestamp: now, Type: models.ContentTypeText, Text: "eggs"},
+	}}}
+	if !reflect.DeepEqual(storages, want) {
+		t.Errorf("imported %+v, want %+v", storages, want)
+	}
+}
+
+func TestParseCSVStorageColumn(t *testing.T) {
+	data := []byte("Storage,Text,Type\nIdeas,paint the fence,location\nideas,plant tulips,\n,call mum,\n")
+	storages, err := Parse("export.csv", "text/csv", data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, storage := range storages {
+		for _, entry := range storage.Entries {
+			if entry.Type != models.ContentTypeText {
+				t.Errorf("%q imported as %s, want text", entry.Text, entry.Type)
+			}
+			got[storage.Name] = append(got[storage.Name], entry.Text)
+		}
+	}
+	want := map[string][]string{"Ideas": {"paint the fence", "plant tulips"}, "export": {"call mum"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported %v, want %v", got, want)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	data := []byte("\xef\xbb\xbffirst line\r\n\n   \n  second line  \n")
+	storages, err := Parse("notes/Reading.txt", "", data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []export.Storage{{Name: "Reading", Mode: models.StorageModeNotes, Entries: []export.Entry{
+		{Timestamp: now, Type: models.ContentTypeText, Text: "first line"},
+		{Timestamp: now, Type: models.ContentTypeText, Text: "second line"},
+	}}}
+	if !reflect.DeepEqual(storages, want) {
+		t.Errorf("imported %+v, want %+v", storages, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		fileName, mimeType string
+		data               string
+		want               error
+	}{
+		{"photo.png", "image/png", "\x89PNG\r\n", ErrUnsupported},
+		{"notes.txt", "", "caf\xe9", ErrUnsupported},
+		{"archive.zip", "application/zip", "PK", ErrUnsupported},
+		{"backup.json", "", "{not json", ErrUnsupported},
+		{"table.csv", "", "a,\"b\nc", ErrUnsupported},
+		{"empty.txt", "", " \n\n", ErrEmpty},
+		{"empty.csv", "", "", ErrEmpty},
+		{"header.csv", "", "storage,text\n", ErrEmpty},
+		{"backup.json", "", `{"storages": []}`, ErrEmpty},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.fileName, tt.mimeType, []byte(tt.data), now); !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%q, %q) = %+v, %v; want %v", tt.fileName, tt.data, got, err, tt.want)
+		}
+	}
+}
+
+func TestStorageName(t *testing.T) {
+	tests := map[string]string{
+		"groceries.txt":      "groceries",
+		"dir/Work notes.csv": "Work notes",
+		"README":             "README",
+		".txt":               "Imported",
+		"":                   "Imported",
+	}
+	for fileName, want := range tests {
+		if got := StorageName(fileName); got != want {
+			t.Errorf("StorageName(%q) = %q, want %q", fileName, got, want)
+		}
+	}
+}
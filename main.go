@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/markDoesany/quickymessenger/blobstore"
 	"github.com/markDoesany/quickymessenger/database"
 	"github.com/markDoesany/quickymessenger/handlers"
+	"github.com/markDoesany/quickymessenger/help"
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/scheduler"
 	"github.com/markDoesany/quickymessenger/services"
 )
 
@@ -17,6 +23,23 @@ func main() {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 	database.InitDB()
+	blobstore.Init()
+
+	localesDir := os.Getenv("LOCALES_DIR")
+	if localesDir == "" {
+		localesDir = "i18n/locales"
+	}
+	if err := i18n.Load(localesDir); err != nil {
+		log.Printf("Warning: Could not load message catalogs: %v", err)
+	}
+
+	helpFile := os.Getenv("HELP_FILE")
+	if helpFile == "" {
+		helpFile = "help/help.json"
+	}
+	if err := help.Load(helpFile); err != nil {
+		log.Printf("Warning: Could not load help content: %v", err)
+	}
 
 	// Set up the persistent menu
 	if err := services.SetupPersistentMenu(); err != nil {
@@ -25,8 +48,20 @@ func main() {
 		log.Println("Persistent menu set up successfully!")
 	}
 
+	reminders := &scheduler.Scheduler{
+		Clock:    scheduler.SystemClock,
+		Interval: 30 * time.Second,
+		Due:      database.DueReminders,
+		Fire:     handlers.SendReminder,
+		MarkSent: database.MarkReminderSent,
+	}
+	go reminders.Run(context.Background())
+	go handlers.ResumeDeletionRequests()
+
 	handler := http.NewServeMux()
 	handler.HandleFunc("/", handlers.Webhook)
+	handler.HandleFunc("/data-deletion", handlers.DataDeletion)
+	handler.HandleFunc(handlers.DeletionStatusPath, handlers.DeletionStatus)
 
 	port := os.Getenv("PORT")
 	if port == "" {
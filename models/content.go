@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/markDoesany/quickymessenger/i18n"
+)
+
+// Content types. Text entries keep plain text in Content.Data; the others
+// keep their payload struct encoded as JSON.
+const (
+	ContentTypeText     = "text"
+	ContentTypeLink     = "link"
+	ContentTypeLocation = "location"
+	ContentTypeContact  = "contact"
+	ContentTypeMedia    = "media"
+)
+
+type LinkPayload struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	FaviconURL  string `json:"favicon_url,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+type LocationPayload struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Title     string  `json:"title,omitempty"`
+}
+
+// MapURL links to the location on a map.
+func (p LocationPayload) MapURL() string {
+	return fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", p.Latitude, p.Longitude)
+}
+
+type ContactPayload struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// MediaPayload describes the Attachment of a media entry. FileName is only
+// set for files.
+type MediaPayload struct {
+	Type     string `json:"type"`
+	FileName string `json:"file_name,omitempty"`
+}
+
+// Payload describes the attachment for the Content.Data of its entry.
+func (a Attachment) Payload() MediaPayload {
+	payload := MediaPayload{Type: a.Type}
+	if a.Type == "file" {
+		payload.FileName = a.FileName
+	}
+	return payload
+}
+
+// EncodePayload turns a payload struct into the string kept in Content.Data.
+func EncodePayload(payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodePayload reads a payload struct from decrypted Content.Data.
+func DecodePayload(data string, payload interface{}) error {
+	return json.Unmarshal([]byte(data), payload)
+}
+
+// DataText renders decrypted entry data of the given type as readable text
+// in locale.
+func DataText(locale, contentType, data string) string {
+	switch contentType {
+	case ContentTypeLink:
+		var link LinkPayload
+		if err := DecodePayload(data, &link); err != nil {
+			return data
+		}
+		lines := []string{}
+		if link.Title != "" {
+			lines = append(lines, link.Title)
+		}
+		if link.Description != "" {
+			lines = append(lines, link.Description)
+		}
+		return strings.Join(append(lines, link.URL), "\n")
+	case ContentTypeLocation:
+		var location LocationPayload
+		if err := DecodePayload(data, &location); err != nil {
+			return data
+		}
+		coordinates := fmt.Sprintf("%.6f, %.6f", location.Latitude, location.Longitude)
+		if location.Title == "" {
+			return i18n.T(locale, "content.location", "location", coordinates)
+		}
+		return i18n.T(locale, "content.location", "location", location.Title) + "\n" + coordinates
+	case ContentTypeContact:
+		var contact ContactPayload
+		if err := DecodePayload(data, &contact); err != nil {
+			return data
+		}
+		lines := []string{i18n.T(locale, "content.contact", "name", contact.Name)}
+		if contact.Phone != "" {
+			lines = append(lines, contact.Phone)
+		}
+		if contact.Email != "" {
+			lines = append(lines, contact.Email)
+		}
+		return strings.Join(lines, "\n")
+	case ContentTypeMedia:
+		var media MediaPayload
+		if err := DecodePayload(data, &media); err != nil || media.Type == "" {
+			return data
+		}
+		label := i18n.T(locale, "content."+media.Type)
+		if media.FileName != "" {
+			return label + ": " + media.FileName
+		}
+		return label
+	default:
+		return data
+	}
+}
+
+// Text renders the decrypted entry as readable text in locale.
+func (c Content) Text(locale string) string {
+	return DataText(locale, c.Type, c.Data)
+}
+
+// Text renders the decrypted revision as readable text in locale.
+func (r ContentRevision) Text(locale string) string {
+	return DataText(locale, r.Type, r.Data)
+}
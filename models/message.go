@@ -20,22 +20,43 @@ type Message struct {
 			} `json:"recipient"`
 			Timestamp int64 `json:"timestamp"`
 			Message   struct {
-				Mid         string `json:"mid"`
-				Text        string `json:"text,omitempty"`
-				Attachments []struct {
-					Type    string `json:"type"`
-					Payload struct {
-						URL string `json:"url"`
-					} `json:"payload"`
-				} `json:"attachments,omitempty"`
+				Mid        string `json:"mid"`
+				Text       string `json:"text,omitempty"`
+				QuickReply struct {
+					Payload string `json:"payload"`
+				} `json:"quick_reply,omitempty"`
+				Attachments []MessageAttachment `json:"attachments,omitempty"`
 			} `json:"message,omitempty"`
 			Postback struct {
-				Payload string `json:"payload"`
+				Payload  string    `json:"payload"`
+				Referral *Referral `json:"referral,omitempty"`
 			} `json:"postback,omitempty"`
+			Referral *Referral `json:"referral,omitempty"`
 		} `json:"messaging"`
 	} `json:"entry"`
 }
 
+// Referral says how a user reached the bot, such as an m.me link; Ref is the
+// link's ref parameter. It arrives on its own in an existing conversation,
+// and with the Get Started postback in a new one.
+type Referral struct {
+	Ref    string `json:"ref"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+type MessageAttachment struct {
+	Type    string `json:"type"`
+	Title   string `json:"title,omitempty"`
+	Payload struct {
+		URL         string `json:"url"`
+		Coordinates *struct {
+			Lat  float64 `json:"lat"`
+			Long float64 `json:"long"`
+		} `json:"coordinates,omitempty"`
+	} `json:"payload"`
+}
+
 type SendMessage struct {
 	Recipient struct {
 		ID string `json:"id"`
@@ -47,15 +68,185 @@ type SendMessage struct {
 
 type Content struct {
 	gorm.Model
-	StorageContentID uint      `gorm:"index"`
-	Timestamp        time.Time `gorm:"not null"`
-	Data             string    `gorm:"type:text;not null"`
+	StorageContentID uint        `gorm:"index"`
+	Timestamp        time.Time   `gorm:"not null"`
+	Type             string      `gorm:"size:16;not null;default:text"`
+	Data             string      `gorm:"type:text;not null"`
+	Done             bool        `gorm:"not null;default:false"` // Ticked off, in checklist storages
+	Attachment       *Attachment `gorm:"foreignKey:ContentID"`
+	Tags             []Tag       `gorm:"many2many:content_tags"`
+}
+
+// Tag is a hashtag a sender attached to entries. Names are stored lowercase
+// without the leading "#".
+type Tag struct {
+	gorm.Model
+	SenderID string    `gorm:"size:255;not null;uniqueIndex:idx_tags_sender_name"`
+	Name     string    `gorm:"size:64;not null;uniqueIndex:idx_tags_sender_name"`
+	Contents []Content `gorm:"many2many:content_tags"`
+}
+
+// Attachment is a file sent to the bot (image, file, audio or video). The
+// file itself is encrypted and kept in the blob store under BlobKey; the
+// owning Content holds its MediaPayload.
+type Attachment struct {
+	gorm.Model
+	ContentID uint   `gorm:"uniqueIndex;not null"`
+	Type      string `gorm:"size:16;not null"`
+	FileName  string `gorm:"size:255"`
+	MimeType  string `gorm:"size:255"`
+	Size      int64
+	BlobKey   string `gorm:"size:255;not null"`
+}
+
+// ContentRevision is an earlier version of a Content, kept encrypted when the
+// entry is edited. Timestamp is when that version was written; CreatedAt is
+// when it was replaced.
+type ContentRevision struct {
+	gorm.Model
+	ContentID uint      `gorm:"index;not null"`
+	Timestamp time.Time `gorm:"not null"`
+	Type      string    `gorm:"size:16;not null;default:text"`
+	Data      string    `gorm:"type:text;not null"`
 }
 
 type StorageContent struct {
 	gorm.Model
 	SenderID    string    `gorm:"size:255;not null"`
 	StorageName string    `gorm:"size:255;not null"`
+	ParentID    *uint     `gorm:"index"` // Folder holding this storage; nil at the top level
+	Mode        string    `gorm:"size:16;not null;default:notes"`
+	PINHash     string    `gorm:"size:60"` // bcrypt hash of the PIN locking the storage; empty when unlocked
 	Contents    []Content `gorm:"foreignKey:StorageContentID"`
 	// DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
+
+// Reminder statuses. A snoozed reminder goes back to pending with a later
+// DueAt.
+const (
+	ReminderPending   = "pending"
+	ReminderSent      = "sent"
+	ReminderDismissed = "dismissed"
+)
+
+// Reminder brings an entry, or a whole storage when ContentID is nil, back to
+// the sender at DueAt.
+type Reminder struct {
+	gorm.Model
+	SenderID         string    `gorm:"size:255;not null;index"`
+	StorageContentID uint      `gorm:"index;not null"`
+	ContentID        *uint     `gorm:"index"`
+	DueAt            time.Time `gorm:"not null;index"`
+	Status           string    `gorm:"size:16;not null;default:pending;index"`
+}
+
+// Storage modes. Entries of a checklist storage are items that can be ticked
+// off.
+const (
+	StorageModeNotes     = "notes"
+	StorageModeChecklist = "checklist"
+)
+
+// StorageRename records a storage's earlier name each time it is renamed.
+type StorageRename struct {
+	gorm.Model
+	StorageContentID uint   `gorm:"index;not null"`
+	SenderID         string `gorm:"size:255;not null"`
+	OldName          string `gorm:"size:255;not null"`
+	NewName          string `gorm:"size:255;not null"`
+}
+
+const (
+	ViewModeFull   = "full"
+	ViewModeDigest = "digest"
+)
+
+// Clock formats for UserPreference.Clock.
+const (
+	Clock12 = "12h"
+	Clock24 = "24h"
+)
+
+// UserPreference holds a sender's settings. Timezone is a zone name such as
+// "Europe/Berlin" or a fixed offset such as "UTC+05:30"; empty means the
+// server's zone. Language is a locale such as "en_US".
+type UserPreference struct {
+	gorm.Model
+	SenderID      string `gorm:"size:255;not null;uniqueIndex"`
+	ViewMode      string `gorm:"size:16;not null;default:full"`
+	Timezone      string `gorm:"size:64;not null;default:''"`
+	Clock         string `gorm:"size:8;not null;default:12h"`
+	DateOrder     string `gorm:"size:8;not null;default:mdy"`
+	Language      string `gorm:"size:16;not null;default:''"`
+	ProfileSeeded bool   `gorm:"not null;default:false"` // Timezone and Language were filled in from the Graph profile
+}
+
+// Deletion sources for DeletionRecord.Source.
+const (
+	DeletionByUser     = "user"
+	DeletionByAdmin    = "admin"
+	DeletionByFacebook = "facebook"
+)
+
+// DeletionRecord is the tombstone left when all of a sender's data is
+// deleted. It keeps no personal data: SenderHash is a keyed hash of the
+// sender ID, so a later request can be matched to it but not reversed.
+type DeletionRecord struct {
+	gorm.Model
+	SenderHash string `gorm:"size:64;not null;index"`
+	Source     string `gorm:"size:16;not null"`
+	Storages   int    `gorm:"not null;default:0"`
+	Entries    int    `gorm:"not null;default:0"`
+	Files      int    `gorm:"not null;default:0"`
+}
+
+// Deletion request statuses.
+const (
+	DeletionRequestPending = "pending"
+	DeletionRequestDone    = "done"
+	DeletionRequestFailed  = "failed"
+)
+
+// DeletionRequest is a data deletion requested through Facebook, looked up
+// by the confirmation code Facebook shows the user. SenderID is only kept
+// until the deletion has run.
+type DeletionRequest struct {
+	gorm.Model
+	Code        string `gorm:"size:32;not null;uniqueIndex"`
+	SenderID    string `gorm:"size:255;not null;default:''"`
+	Status      string `gorm:"size:16;not null;default:pending"`
+	CompletedAt *time.Time
+}
+
+// Share roles. Viewers can read a shared storage; contributors can also add,
+// edit and remove its entries. Only the owner can rename, move, merge,
+// delete or share it.
+const (
+	ShareRoleViewer      = "viewer"
+	ShareRoleContributor = "contributor"
+)
+
+// StorageShare gives SenderID access to a storage another sender owns.
+type StorageShare struct {
+	gorm.Model
+	StorageContentID uint   `gorm:"not null;uniqueIndex:idx_storage_shares_storage_sender"`
+	SenderID         string `gorm:"size:255;not null;uniqueIndex:idx_storage_shares_storage_sender;index"`
+	Role             string `gorm:"size:16;not null;default:viewer"`
+}
+
+// ShareInvite is a single-use code that gives whoever redeems it Role on a
+// storage, until ExpiresAt.
+type ShareInvite struct {
+	gorm.Model
+	Code             string    `gorm:"size:16;not null;uniqueIndex"`
+	StorageContentID uint      `gorm:"not null;index"`
+	Role             string    `gorm:"size:16;not null"`
+	ExpiresAt        time.Time `gorm:"not null"`
+}
+
+// SchemaMigration records a data migration that has run, so that it only
+// runs once.
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,18 @@
+package scheduler
+
+import "time"
+
+// Clock tells the scheduler the time and lets it wait. Tests substitute a
+// clock they control.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
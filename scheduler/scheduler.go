@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+)
+
+// batchSize caps how many reminders one tick delivers, so a backlog after
+// downtime is worked off over several ticks.
+const batchSize = 100
+
+// Scheduler delivers due reminders. Reminders live in the database, so the
+// scheduler keeps no state of its own: after a restart it picks up whatever
+// fell due in the meantime on its first tick.
+type Scheduler struct {
+	Clock    Clock
+	Interval time.Duration
+	// Due returns pending reminders due at or before now.
+	Due func(now time.Time, limit int) ([]models.Reminder, error)
+	// Fire delivers a reminder to its sender.
+	Fire func(reminder models.Reminder) error
+	// MarkSent records a delivered reminder so it doesn't fire again.
+	MarkSent func(reminderID uint) error
+}
+
+// Run ticks every Interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.Tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.Clock.After(s.Interval):
+		}
+	}
+}
+
+// Tick delivers the reminders due now and returns how many were sent. A
+// reminder that fails to send stays pending and is retried on the next tick.
+func (s *Scheduler) Tick() int {
+	reminders, err := s.Due(s.Clock.Now(), batchSize)
+	if err != nil {
+		log.Printf("Failed to load due reminders: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		if err := s.Fire(reminder); err != nil {
+			log.Printf("Failed to send reminder %d: %v", reminder.ID, err)
+			continue
+		}
+		if err := s.MarkSent(reminder.ID); err != nil {
+			log.Printf("Failed to mark reminder %d as sent: %v", reminder.ID, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
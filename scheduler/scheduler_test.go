@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/models"
+	"gorm.io/gorm"
+)
+
+// fakeClock only moves when a test advances it.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// store keeps reminders in memory the way the database package does.
+type store struct {
+	mu        sync.Mutex
+	reminders map[uint]*models.Reminder
+	fired     []uint
+	failNext  bool
+}
+
+func newStore(reminders ...models.Reminder) *store {
+	s := &store{reminders: map[uint]*models.Reminder{}}
+	for i := range reminders {
+		reminders[i].Status = models.ReminderPending
+		s.reminders[reminders[i].ID] = &reminders[i]
+	}
+	return s
+}
+
+func (s *store) Due(now time.Time, limit int) ([]models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := []models.Reminder{}
+	for _, r := range s.reminders {
+		if r.Status == models.ReminderPending && !r.DueAt.After(now) {
+			due = append(due, *r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DueAt.Before(due[j].DueAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *store) Fire(reminder models.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failNext {
+		s.failNext = false
+		return errors.New("send failed")
+	}
+	s.fired = append(s.fired, reminder.ID)
+	return nil
+}
+
+func (s *store) MarkSent(reminderID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.reminders[reminderID]; ok && r.Status == models.ReminderPending {
+		r.Status = models.ReminderSent
+	}
+	return nil
+}
+
+// Snooze mirrors database.SnoozeReminder.
+func (s *store) Snooze(reminderID uint, dueAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reminders[reminderID].Status = models.ReminderPending
+	s.reminders[reminderID].DueAt = dueAt
+}
+
+func (s *store) Fired() []uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint(nil), s.fired...)
+}
+
+var start = time.Date(2026, time.March, 18, 9, 0, 0, 0, time.UTC)
+
+func newScheduler(clock Clock, s *store) *Scheduler {
+	return &Scheduler{Clock: clock, Interval: time.Minute, Due: s.Due, Fire: s.Fire, MarkSent: s.MarkSent}
+}
+
+func reminder(id uint, dueAt time.Time) models.Reminder {
+	return models.Reminder{Model: gorm.Model{ID: id}, SenderID: "user", StorageContentID: 1, DueAt: dueAt}
+}
+
+func TestDueReminderFiresOnce(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := newStore(reminder(1, start.Add(-time.Minute)))
+	sched := newScheduler(clock, s)
+
+	if sent := sched.Tick(); sent != 1 {
+		t.Fatalf("first tick sent %d, want 1", sent)
+	}
+	clock.Advance(time.Minute)
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("second tick sent %d, want 0", sent)
+	}
+	if fired := s.Fired(); len(fired) != 1 || fired[0] != 1 {
+		t.Fatalf("fired %v, want [1]", fired)
+	}
+}
+
+func TestFutureReminderWaits(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := newStore(reminder(1, start.Add(time.Hour)))
+	sched := newScheduler(clock, s)
+
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("tick before due sent %d, want 0", sent)
+	}
+	clock.Advance(59 * time.Minute)
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("tick a minute early sent %d, want 0", sent)
+	}
+	clock.Advance(time.Minute)
+	if sent := sched.Tick(); sent != 1 {
+		t.Fatalf("tick when due sent %d, want 1", sent)
+	}
+}
+
+func TestSnoozeRequeues(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := newStore(reminder(1, start))
+	sched := newScheduler(clock, s)
+
+	if sent := sched.Tick(); sent != 1 {
+		t.Fatalf("tick sent %d, want 1", sent)
+	}
+	s.Snooze(1, clock.Now().Add(10*time.Minute))
+
+	clock.Advance(5 * time.Minute)
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("tick while snoozed sent %d, want 0", sent)
+	}
+	clock.Advance(5 * time.Minute)
+	if sent := sched.Tick(); sent != 1 {
+		t.Fatalf("tick after snooze sent %d, want 1", sent)
+	}
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("tick after the snoozed reminder fired sent %d, want 0", sent)
+	}
+	if fired := s.Fired(); len(fired) != 2 {
+		t.Fatalf("fired %v, want the reminder twice", fired)
+	}
+}
+
+func TestFailedReminderIsRetried(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := newStore(reminder(1, start))
+	s.failNext = true
+	sched := newScheduler(clock, s)
+
+	if sent := sched.Tick(); sent != 0 {
+		t.Fatalf("failing tick sent %d, want 0", sent)
+	}
+	if sent := sched.Tick(); sent != 1 {
+		t.Fatalf("retry sent %d, want 1", sent)
+	}
+}
+
+func TestRunTicksEveryInterval(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := newStore(reminder(1, start), reminder(2, start.Add(time.Minute)))
+	sched := newScheduler(clock, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+
+	waitFor(t, func() bool { return len(s.Fired()) == 1 && clock.Waiting() == 1 })
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return len(s.Fired()) == 2 && clock.Waiting() == 1 })
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't stop when cancelled")
+	}
+	if fired := s.Fired(); fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("fired %v, want [1 2]", fired)
+	}
+}
+
+func waitFor(t *testing.T, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"time"
+)
+
+// MaxAttachmentSize is the largest file Messenger accepts as an attachment.
+const MaxAttachmentSize = 25 << 20
+
+var attachmentClient = &http.Client{Timeout: 60 * time.Second}
+
+// DownloadAttachment fetches the file behind an attachment payload URL. Those
+// URLs expire, so attachments are downloaded as soon as they arrive.
+func DownloadAttachment(attachmentURL string) (data []byte, mimeType, fileName string, err error) {
+	res, err := attachmentClient.Get(attachmentURL)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to download attachment: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("failed to download attachment (status %d)", res.StatusCode)
+	}
+
+	data, err = io.ReadAll(io.LimitReader(res.Body, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, "", "", fmt.Errorf("attachment is larger than %d bytes", MaxAttachmentSize)
+	}
+
+	mimeType, _, _ = mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if u, err := url.Parse(attachmentURL); err == nil {
+		fileName = path.Base(u.Path)
+	}
+	return data, mimeType, fileName, nil
+}
+
+// UploadAttachment uploads a file through the Send API attachment upload
+// endpoint and returns the attachment ID to send it with.
+func UploadAttachment(attachmentType, fileName, mimeType string, data []byte) (string, error) {
+	message, err := json.Marshal(map[string]interface{}{
+		"attachment": map[string]interface{}{
+			"type":    attachmentType,
+			"payload": map[string]bool{"is_reusable": false},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling request: %w", err)
+	}
+
+	if fileName == "" || fileName == "." || fileName == "/" {
+		fileName = attachmentType
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("message", string(message)); err != nil {
+		return "", err
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="filedata"; filename=%q`, fileName))
+	header.Set("Content-Type", mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/%s?access_token=%s", os.Getenv("GRAPHQL_URL"), "me/message_attachments", os.Getenv("ACCESS_TOKEN"))
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	res, err := attachmentClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error uploading attachment (status %d): %s", res.StatusCode, string(resBody))
+	}
+
+	var result struct {
+		AttachmentID string `json:"attachment_id"`
+	}
+	if err := json.Unmarshal(resBody, &result); err != nil {
+		return "", fmt.Errorf("error reading upload response: %w", err)
+	}
+	return result.AttachmentID, nil
+}
+
+func AttachmentMessage(senderID, attachmentType, attachmentID string) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type":    attachmentType,
+				"payload": map[string]string{"attachment_id": attachmentID},
+			},
+		},
+	}
+}
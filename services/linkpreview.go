@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// maxPageSize caps how much of a page is read when looking for its metadata.
+const maxPageSize = 1 << 20
+
+// LinkMetadata is what a page says about itself in its <head>.
+type LinkMetadata struct {
+	Title       string
+	Description string
+	FaviconURL  string
+	ImageURL    string
+}
+
+// LinkFetcher looks up the metadata of web pages. The HTTP client is
+// injectable so a local server can stand in for the web.
+type LinkFetcher struct {
+	Client *http.Client
+}
+
+// NewLinkFetcher returns a fetcher using client, or a client that refuses to
+// connect to private and loopback addresses when client is nil.
+func NewLinkFetcher(client *http.Client) *LinkFetcher {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicAddressesOnly}
+		client = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: http.ProxyFromEnvironment},
+		}
+	}
+	return &LinkFetcher{Client: client}
+}
+
+var errPrivateAddress = errors.New("refusing to connect to a private address")
+
+// publicAddressesOnly stops links sent by users from reaching hosts on the
+// bot's own network.
+func publicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// Fetch downloads the page at pageURL and extracts its title, description,
+// favicon and preview image from Open Graph and HTML meta tags.
+func (f *LinkFetcher) Fetch(ctx context.Context, pageURL string) (LinkMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return LinkMetadata{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "QuickyStorageBot/1.0 (+link preview)")
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return LinkMetadata{}, fmt.Errorf("failed to fetch link: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return LinkMetadata{}, fmt.Errorf("failed to fetch link (status %d)", res.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return LinkMetadata{}, fmt.Errorf("link is not a web page (%s)", mediaType)
+	}
+
+	// Redirects may have moved us, and relative URLs resolve against the final page.
+	metadata := ParseLinkMetadata(io.LimitReader(res.Body, maxPageSize), res.Request.URL)
+	return metadata, nil
+}
+
+// ParseLinkMetadata reads page metadata from HTML, resolving relative URLs
+// against base. Open Graph tags win over their plain HTML counterparts.
+func ParseLinkMetadata(r io.Reader, base *url.URL) LinkMetadata {
+	var metadata LinkMetadata
+	var title, description string
+	tokenizer := html.NewTokenizer(r)
+
+	inTitle := false
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(token.Data)
+			}
+			continue
+		case html.EndTagToken:
+			if token.Data == "title" {
+				inTitle = false
+			}
+			if token.Data == "head" {
+				return finishMetadata(metadata, title, description, base)
+			}
+			continue
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, attr := range token.Attr {
+			attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+		}
+
+		switch token.Data {
+		case "title":
+			inTitle = true
+		case "meta":
+			key := strings.ToLower(attrs["property"])
+			if key == "" {
+				key = strings.ToLower(attrs["name"])
+			}
+			content := attrs["content"]
+			switch key {
+			case "og:title":
+				metadata.Title = content
+			case "og:description":
+				metadata.Description = content
+			case "og:image":
+				metadata.ImageURL = resolveURL(base, content)
+			case "description":
+				description = content
+			}
+		case "link":
+			rel := strings.Fields(strings.ToLower(attrs["rel"]))
+			for _, r := range rel {
+				if (r == "icon" || r == "apple-touch-icon") && metadata.FaviconURL == "" {
+					metadata.FaviconURL = resolveURL(base, attrs["href"])
+				}
+			}
+		case "body":
+			// Everything we look for lives in <head>.
+			return finishMetadata(metadata, title, description, base)
+		}
+	}
+	return finishMetadata(metadata, title, description, base)
+}
+
+func finishMetadata(metadata LinkMetadata, title, description string, base *url.URL) LinkMetadata {
+	if metadata.Title == "" {
+		metadata.Title = title
+	}
+	if metadata.Description == "" {
+		metadata.Description = description
+	}
+	if metadata.FaviconURL == "" && base != nil {
+		metadata.FaviconURL = resolveURL(base, "/favicon.ico")
+	}
+	return metadata
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
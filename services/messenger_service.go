@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/markDoesany/quickymessenger/i18n"
 	"github.com/markDoesany/quickymessenger/templates"
 )
 
@@ -68,15 +69,15 @@ func PayloadMessage(senderID, payload string) SendRequestFunc {
 
 // ListStoragesMessage creates a message with a list of storages
 // Uses carousel template when there are more than 3 storages
-func ListStoragesMessage(senderID string, storages []string) map[string]interface{} {
+func ListStoragesMessage(senderID, locale string, storages []templates.StorageItem) map[string]interface{} {
 	// If 3 or fewer storages, use button template for simplicity
 	if len(storages) <= 3 {
 		buttons := make([]map[string]string, len(storages))
 		for i, storage := range storages {
 			buttons[i] = map[string]string{
 				"type":    "postback",
-				"title":   storage,
-				"payload": "STORAGE_" + strconv.Itoa(i+1),
+				"title":   storage.Title,
+				"payload": storage.Payload,
 			}
 		}
 
@@ -87,7 +88,7 @@ func ListStoragesMessage(senderID string, storages []string) map[string]interfac
 					"type": "template",
 					"payload": map[string]interface{}{
 						"template_type": "button",
-						"text":          "Select a storage:",
+						"text":          i18n.T(locale, "storage.select"),
 						"buttons":       buttons,
 					},
 				},
@@ -96,10 +97,10 @@ func ListStoragesMessage(senderID string, storages []string) map[string]interfac
 	}
 
 	// For more than 3 storages, use carousel template
-	return templates.StorageCarouselTemplate(senderID, storages, 0)
+	return templates.StorageCarouselTemplate(senderID, locale, storages, 0, "", "", "")
 }
 
-func RemoveListStoragesMessage(senderID string, storages []string) map[string]interface{} {
+func RemoveListStoragesMessage(senderID, locale string, storages []string) map[string]interface{} {
 	buttons := make([]map[string]string, len(storages))
 	for i, storage := range storages {
 		buttons[i] = map[string]string{
@@ -116,7 +117,7 @@ func RemoveListStoragesMessage(senderID string, storages []string) map[string]in
 				"type": "template",
 				"payload": map[string]interface{}{
 					"template_type": "button",
-					"text":          "Select a storage:",
+					"text":          i18n.T(locale, "storage.select"),
 					"buttons":       buttons,
 				},
 			},
@@ -124,7 +125,7 @@ func RemoveListStoragesMessage(senderID string, storages []string) map[string]in
 	}
 }
 
-func RemoveListStorages(senderID string, storages []string) map[string]interface{} {
+func RemoveListStorages(senderID, locale string, storages []string) map[string]interface{} {
 	buttons := make([]map[string]string, len(storages))
 	for i, storage := range storages {
 		buttons[i] = map[string]string{
@@ -141,7 +142,7 @@ func RemoveListStorages(senderID string, storages []string) map[string]interface
 				"type": "template",
 				"payload": map[string]interface{}{
 					"template_type": "button",
-					"text":          "Select a storage:",
+					"text":          i18n.T(locale, "storage.select"),
 					"buttons":       buttons,
 				},
 			},
@@ -149,37 +150,55 @@ func RemoveListStorages(senderID string, storages []string) map[string]interface
 	}
 }
 
-// SetupPersistentMenu configures the persistent menu for the Messenger bot
+// persistentMenu builds the persistent menu in one language for the given
+// Messenger locale.
+func persistentMenu(locale, language string) map[string]interface{} {
+	return map[string]interface{}{
+		"locale":                  locale,
+		"composer_input_disabled": false,
+		"call_to_actions": []map[string]interface{}{
+			// My Account
+			{"type": "postback", "title": i18n.T(language, "menu.view_profile"), "payload": "VIEW_PROFILE"},
+			{"type": "postback", "title": i18n.T(language, "menu.create_storage"), "payload": "CREATE_STORAGE"},
+			{"type": "postback", "title": i18n.T(language, "menu.browse_tags"), "payload": "BROWSE_TAGS_PAYLOAD"},
+			{"type": "postback", "title": i18n.T(language, "menu.export_data"), "payload": "EXPORT_ALL_PAYLOAD"},
+			{"type": "postback", "title": i18n.T(language, "menu.import_data"), "payload": "IMPORT_PAYLOAD"},
+			{"type": "postback", "title": i18n.T(language, "menu.my_data"), "payload": "MY_DATA_PAYLOAD"},
+			{"type": "postback", "title": i18n.T(language, "menu.billing_statement"), "payload": "BILLING_STATEMENT"},
+			{"type": "postback", "title": i18n.T(language, "menu.payment_history"), "payload": "PAYMENT_HISTORY"},
+			{"type": "postback", "title": i18n.T(language, "menu.update_info"), "payload": "UPDATE_INFO"},
+			// Requests & Concerns
+			{"type": "postback", "title": i18n.T(language, "menu.maintenance_request"), "payload": "MAINTENANCE_REQUEST"},
+			{"type": "postback", "title": i18n.T(language, "menu.book_amenity"), "payload": "BOOK_AMENITY"},
+			{"type": "postback", "title": i18n.T(language, "menu.visitor_pass"), "payload": "VISITOR_PASS"},
+			{"type": "postback", "title": i18n.T(language, "menu.complaint"), "payload": "COMPLAINT"},
+			{"type": "postback", "title": i18n.T(language, "menu.feedback"), "payload": "FEEDBACK"},
+			// Community
+			{"type": "postback", "title": i18n.T(language, "menu.announcements"), "payload": "ANNOUNCEMENTS"},
+			{"type": "postback", "title": i18n.T(language, "menu.events"), "payload": "EVENTS"},
+			{"type": "postback", "title": i18n.T(language, "menu.buy_sell_board"), "payload": "BUY_SELL_BOARD"},
+			{"type": "postback", "title": i18n.T(language, "menu.contact_admin"), "payload": "CONTACT_ADMIN"},
+			{"type": "postback", "title": i18n.T(language, "menu.community_guidelines"), "payload": "COMMUNITY_GUIDELINES"},
+		},
+	}
+}
+
+// SetupPersistentMenu configures the persistent menu for the Messenger bot,
+// once per Messenger locale served by a loaded catalog. Other locales get the
+// fallback language.
 func SetupPersistentMenu() error {
 	log.Println("Setting up persistent menu...")
 
+	menus := []map[string]interface{}{persistentMenu("default", i18n.Fallback)}
+	for _, language := range i18n.Languages() {
+		for _, locale := range i18n.MessengerLocales(language) {
+			menus = append(menus, persistentMenu(locale, language))
+		}
+	}
+	// Get Started is what brings an m.me link's referral to new users.
 	payload := map[string]interface{}{
-		"persistent_menu": []map[string]interface{}{
-			{
-				"locale":                  "default",
-				"composer_input_disabled": false,
-				"call_to_actions": []map[string]interface{}{
-					// My Account
-					{"type": "postback", "title": "View Profile", "payload": "VIEW_PROFILE"},
-					{"type": "postback", "title": "Create Storage", "payload": "CREATE_STORAGE"},
-					{"type": "postback", "title": "Billing Statement", "payload": "BILLING_STATEMENT"},
-					{"type": "postback", "title": "Payment History", "payload": "PAYMENT_HISTORY"},
-					{"type": "postback", "title": "Update Info", "payload": "UPDATE_INFO"},
-					// Requests & Concerns
-					{"type": "postback", "title": "Maintenance Request", "payload": "MAINTENANCE_REQUEST"},
-					{"type": "postback", "title": "Book Amenity", "payload": "BOOK_AMENITY"},
-					{"type": "postback", "title": "Visitor Pass", "payload": "VISITOR_PASS"},
-					{"type": "postback", "title": "Complaint", "payload": "COMPLAINT"},
-					{"type": "postback", "title": "Feedback", "payload": "FEEDBACK"},
-					// Community
-					{"type": "postback", "title": "Announcements", "payload": "ANNOUNCEMENTS"},
-					{"type": "postback", "title": "Events", "payload": "EVENTS"},
-					{"type": "postback", "title": "Buy/Sell Board", "payload": "BUY_SELL_BOARD"},
-					{"type": "postback", "title": "Contact Admin", "payload": "CONTACT_ADMIN"},
-					{"type": "postback", "title": "Community Guidelines", "payload": "COMMUNITY_GUIDELINES"},
-				},
-			},
-		},
+		"get_started":     map[string]string{"payload": "GET_STARTED_PAYLOAD"},
+		"persistent_menu": menus,
 	}
 
 	// Log the payload structure for debugging
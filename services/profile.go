@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+var profileClient = &http.Client{Timeout: 10 * time.Second}
+
+// UserProfile is the part of a Messenger user's Graph profile the bot uses.
+// Locale looks like "en_US"; Timezone is the offset from UTC in hours. Both
+// are empty when the page lacks permission to read them.
+type UserProfile struct {
+	FirstName string   `json:"first_name"`
+	Locale    string   `json:"locale"`
+	Timezone  *float64 `json:"timezone"`
+}
+
+// GetUserProfile fetches the first name, locale and time zone of the user
+// with the given page-scoped ID.
+func GetUserProfile(senderID string) (UserProfile, error) {
+	endpoint := fmt.Sprintf("%s/%s?fields=first_name,locale,timezone&access_token=%s", os.Getenv("GRAPHQL_URL"), url.PathEscape(senderID), os.Getenv("ACCESS_TOKEN"))
+	res, err := profileClient.Get(endpoint)
+	if err != nil {
+		return UserProfile{}, fmt.Errorf("failed to fetch user profile: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return UserProfile{}, fmt.Errorf("failed to fetch user profile: status %s", res.Status)
+	}
+
+	var profile UserProfile
+	if err := json.NewDecoder(res.Body).Decode(&profile); err != nil {
+		return UserProfile{}, fmt.Errorf("failed to decode user profile: %w", err)
+	}
+	return profile, nil
+}
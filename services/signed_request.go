@@ -0,0 +1,55 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSignedRequest = errors.New("invalid signed request")
+
+// SignedRequest is the payload of a signed_request Facebook posts to app
+// callbacks such as data deletion.
+type SignedRequest struct {
+	Algorithm string `json:"algorithm"`
+	IssuedAt  int64  `json:"issued_at"`
+	UserID    string `json:"user_id"`
+}
+
+// ParseSignedRequest verifies a signed_request ("signature.payload", both
+// base64url) against the app secret and decodes its payload.
+func ParseSignedRequest(signedRequest, appSecret string) (SignedRequest, error) {
+	var request SignedRequest
+	if appSecret == "" {
+		return request, fmt.Errorf("%w: no app secret configured", ErrInvalidSignedRequest)
+	}
+	encodedSig, payload, found := strings.Cut(signedRequest, ".")
+	if !found {
+		return request, ErrInvalidSignedRequest
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encodedSig, "="))
+	if err != nil {
+		return request, fmt.Errorf("%w: %v", ErrInvalidSignedRequest, err)
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(payload))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return request, fmt.Errorf("%w: bad signature", ErrInvalidSignedRequest)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return request, fmt.Errorf("%w: %v", ErrInvalidSignedRequest, err)
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return request, fmt.Errorf("%w: %v", ErrInvalidSignedRequest, err)
+	}
+	if !strings.EqualFold(request.Algorithm, "HMAC-SHA256") || request.UserID == "" {
+		return request, fmt.Errorf("%w: unexpected payload", ErrInvalidSignedRequest)
+	}
+	return request, nil
+}
@@ -1,8 +1,21 @@
 package templates
 
-import "fmt"
+import (
+	"fmt"
 
-func ButtonTemplateGetStarted(senderID string) map[string]interface{} {
+	"github.com/markDoesany/quickymessenger/i18n"
+	"github.com/markDoesany/quickymessenger/utils"
+)
+
+// Card is one element of a generic template.
+type Card struct {
+	Title    string
+	Subtitle string
+	ImageURL string
+	Buttons  []map[string]string
+}
+
+func ButtonTemplateGetStarted(senderID, locale string) map[string]interface{} {
 	return map[string]interface{}{
 		"recipient": map[string]string{"id": senderID},
 		"message": map[string]interface{}{
@@ -10,16 +23,16 @@ func ButtonTemplateGetStarted(senderID string) map[string]interface{} {
 				"type": "template",
 				"payload": map[string]interface{}{
 					"template_type": "button",
-					"text":          "What would you like to do?",
+					"text":          i18n.T(locale, "menu.prompt"),
 					"buttons": []map[string]string{
 						{
 							"type":    "postback",
-							"title":   "Get Started ",
+							"title":   i18n.T(locale, "button.get_started"),
 							"payload": "GET_STARTED_PAYLOAD",
 						},
 						{
 							"type":    "postback",
-							"title":   "Help ",
+							"title":   i18n.T(locale, "button.help"),
 							"payload": "HELP_PAYLOAD",
 						},
 					},
@@ -29,7 +42,7 @@ func ButtonTemplateGetStarted(senderID string) map[string]interface{} {
 	}
 }
 
-func ButtonTemplateMessage(senderID string) map[string]interface{} {
+func ButtonTemplateMessage(senderID, locale string) map[string]interface{} {
 	return map[string]interface{}{
 		"recipient": map[string]string{"id": senderID},
 		"message": map[string]interface{}{
@@ -37,21 +50,21 @@ func ButtonTemplateMessage(senderID string) map[string]interface{} {
 				"type": "template",
 				"payload": map[string]interface{}{
 					"template_type": "button",
-					"text":          "What would you like to do?",
+					"text":          i18n.T(locale, "menu.prompt"),
 					"buttons": []map[string]string{
 						{
 							"type":    "postback",
-							"title":   "Search Storage ",
+							"title":   i18n.T(locale, "button.search_storage"),
 							"payload": "SEARCH_STORAGE_PAYLOAD",
 						},
 						{
 							"type":    "postback",
-							"title":   "Create Storage ",
+							"title":   i18n.T(locale, "button.create_storage"),
 							"payload": "CREATE_STORAGE_PAYLOAD",
 						},
 						{
 							"type":    "postback",
-							"title":   "Remove Storage ",
+							"title":   i18n.T(locale, "button.remove_storage"),
 							"payload": "REMOVE_STORAGE_PAYLOAD",
 						},
 					},
@@ -61,7 +74,7 @@ func ButtonTemplateMessage(senderID string) map[string]interface{} {
 	}
 }
 
-func ButtonTemplateAddOrExit(senderID string) map[string]interface{} {
+func ButtonTemplateAddOrExit(senderID, locale string) map[string]interface{} {
 	return map[string]interface{}{
 		"recipient": map[string]string{"id": senderID},
 		"message": map[string]interface{}{
@@ -69,16 +82,21 @@ func ButtonTemplateAddOrExit(senderID string) map[string]interface{} {
 				"type": "template",
 				"payload": map[string]interface{}{
 					"template_type": "button",
-					"text":          "Do you want to add more data or exit?",
+					"text":          i18n.T(locale, "storage.add_or_exit"),
 					"buttons": []map[string]string{
 						{
 							"type":    "postback",
-							"title":   "Add Data",
+							"title":   i18n.T(locale, "button.add_data"),
 							"payload": "ADD_DATA_PAYLOAD",
 						},
 						{
 							"type":    "postback",
-							"title":   "Exit",
+							"title":   i18n.T(locale, "button.options"),
+							"payload": "STORAGE_OPTIONS_PAYLOAD",
+						},
+						{
+							"type":    "postback",
+							"title":   i18n.T(locale, "button.exit"),
 							"payload": "EXIT_PAYLOAD",
 						},
 					},
@@ -88,27 +106,270 @@ func ButtonTemplateAddOrExit(senderID string) map[string]interface{} {
 	}
 }
 
-func ButtonTemplateShowMoreOrExit(senderID string) map[string]interface{} {
+func ButtonTemplateShowMoreOrExit(senderID, locale string) map[string]interface{} {
 	return map[string]interface{}{
-		"attachment": map[string]interface{}{
-			"type": "template",
-			"payload": map[string]interface{}{
-				"template_type": "button",
-				"text":          "Do you want to see more data or exit?",
-				"buttons": []map[string]string{
-					{"type": "postback", "title": "Show More", "payload": "SHOW_MORE_PAYLOAD"},
-					{"type": "postback", "title": "Exit", "payload": "EXIT_PAYLOAD"},
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          i18n.T(locale, "storage.more_or_exit"),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.show_more"), "payload": "SHOW_MORE_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.add_data"), "payload": "ADD_DATA_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.exit"), "payload": "EXIT_PAYLOAD"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EntryActionsTemplate shows an entry with buttons to edit or delete it, and
+// a More button for the less common actions.
+// Button templates hold at most 640 characters of text.
+func EntryActionsTemplate(senderID, locale, text string, contentID uint) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.edit"), "payload": fmt.Sprintf("EDIT_ENTRY_%d", contentID)},
+						{"type": "postback", "title": i18n.T(locale, "button.delete"), "payload": fmt.Sprintf("DELETE_ENTRY_%d", contentID)},
+						{"type": "postback", "title": i18n.T(locale, "button.more"), "payload": fmt.Sprintf("MORE_ENTRY_%d", contentID)},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EntryMoreActionsMessage offers the less common entry actions as quick replies.
+func EntryMoreActionsMessage(senderID, locale string, contentID uint) map[string]interface{} {
+	return QuickReplyMessage(senderID, i18n.T(locale, "entry.more_prompt"), []map[string]string{
+		{"title": i18n.T(locale, "button.history"), "payload": fmt.Sprintf("HISTORY_ENTRY_%d", contentID)},
+		{"title": i18n.T(locale, "button.add_tags"), "payload": fmt.Sprintf("TAG_ENTRY_%d", contentID)},
+		{"title": i18n.T(locale, "button.move"), "payload": fmt.Sprintf("MOVE_ENTRY_%d", contentID)},
+		{"title": i18n.T(locale, "button.copy"), "payload": fmt.Sprintf("COPY_ENTRY_%d", contentID)},
+		{"title": i18n.T(locale, "button.remind_me"), "payload": fmt.Sprintf("REMIND_ENTRY_%d", contentID)},
+	})
+}
+
+func ButtonTemplateConfirmDeleteEntry(senderID, locale string, contentID uint) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          i18n.T(locale, "entry.confirm_delete"),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.delete"), "payload": fmt.Sprintf("CONFIRM_DELETE_ENTRY_%d", contentID)},
+						{"type": "postback", "title": i18n.T(locale, "button.cancel"), "payload": "EXIT_PAYLOAD"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func ButtonTemplateConfirmRemoveStorage(senderID, locale, text string, storageID uint) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.remove"), "payload": fmt.Sprintf("CONFIRM_REMOVE_STORAGE_%d", storageID)},
+						{"type": "postback", "title": i18n.T(locale, "button.cancel"), "payload": "EXIT_PAYLOAD"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ButtonTemplateConfirmImport shows what importing a file would store, with
+// buttons to import it or cancel.
+func ButtonTemplateConfirmImport(senderID, locale, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.import"), "payload": "CONFIRM_IMPORT_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.cancel"), "payload": "CANCEL_IMPORT_PAYLOAD"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ButtonTemplateMyData lists what is stored about the sender, with buttons
+// to download it or delete all of it.
+func ButtonTemplateMyData(senderID, locale, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.export_data"), "payload": "EXPORT_ALL_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.delete_everything"), "payload": "DELETE_ALL_DATA_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.exit"), "payload": "EXIT_PAYLOAD"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ButtonTemplateConfirmDeleteAll asks the sender to confirm deleting all of
+// their data.
+func ButtonTemplateConfirmDeleteAll(senderID, locale, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.delete_everything"), "payload": "CONFIRM_DELETE_ALL_DATA_PAYLOAD"},
+						{"type": "postback", "title": i18n.T(locale, "button.cancel"), "payload": "EXIT_PAYLOAD"},
+					},
 				},
 			},
 		},
 	}
 }
 
-// StorageCarouselTemplate creates a carousel of storage options with up to 3 buttons per card
-func StorageCarouselTemplate(senderID string, storages []string, startIndex int) map[string]interface{} {
-	const maxItems = 10         // Facebook's limit for carousel items
+// ReminderTemplate delivers a reminder with buttons to snooze it for an hour
+// or a day, or to dismiss it.
+func ReminderTemplate(senderID, locale, text string, reminderID uint) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "button",
+					"text":          utils.Truncate(text, 640),
+					"buttons": []map[string]string{
+						{"type": "postback", "title": i18n.T(locale, "button.snooze_hour"), "payload": fmt.Sprintf("SNOOZE_REMINDER_%d_60", reminderID)},
+						{"type": "postback", "title": i18n.T(locale, "button.snooze_day"), "payload": fmt.Sprintf("SNOOZE_REMINDER_%d_1440", reminderID)},
+						{"type": "postback", "title": i18n.T(locale, "button.dismiss"), "payload": fmt.Sprintf("DISMISS_REMINDER_%d", reminderID)},
+					},
+				},
+			},
+		},
+	}
+}
+
+// QuickReplyMessage creates a text message with quick reply chips. Messenger
+// accepts at most 13 chips with titles of up to 20 characters.
+func QuickReplyMessage(senderID, text string, replies []map[string]string) map[string]interface{} {
+	const maxQuickReplies = 13
+	if len(replies) > maxQuickReplies {
+		replies = replies[:maxQuickReplies]
+	}
+
+	quickReplies := make([]map[string]string, 0, len(replies))
+	for _, reply := range replies {
+		quickReplies = append(quickReplies, map[string]string{
+			"content_type": "text",
+			"title":        utils.Truncate(reply["title"], 20),
+			"payload":      reply["payload"],
+		})
+	}
+
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"text":          text,
+			"quick_replies": quickReplies,
+		},
+	}
+}
+
+// CardCarouselTemplate creates a generic template from cards. Messenger shows at
+// most 10 cards, with titles and subtitles of up to 80 characters each.
+func CardCarouselTemplate(senderID string, cards []Card) map[string]interface{} {
+	const maxItems = 10
+	if len(cards) > maxItems {
+		cards = cards[:maxItems]
+	}
+
+	elements := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		element := map[string]interface{}{
+			"title": utils.Truncate(card.Title, 80),
+		}
+		if card.Subtitle != "" {
+			element["subtitle"] = utils.Truncate(card.Subtitle, 80)
+		}
+		if card.ImageURL != "" {
+			element["image_url"] = card.ImageURL
+		}
+		if len(card.Buttons) > 0 {
+			element["buttons"] = card.Buttons
+		}
+		elements = append(elements, element)
+	}
+
+	return map[string]interface{}{
+		"recipient": map[string]string{"id": senderID},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": "template",
+				"payload": map[string]interface{}{
+					"template_type": "generic",
+					"elements":      elements,
+				},
+			},
+		},
+	}
+}
+
+// StorageItem is one button of the storage browser.
+type StorageItem struct {
+	Title   string
+	Payload string
+}
+
+// StorageCarouselTemplate creates a carousel of storage options with up to 3 buttons per card.
+// breadcrumb titles the cards with the folder being browsed; when upPayload is
+// set a navigation card leads back to the parent folder, and openPayload, if
+// set, shows the entries of the folder itself.
+func StorageCarouselTemplate(senderID, locale string, storages []StorageItem, startIndex int, breadcrumb, upPayload, openPayload string) map[string]interface{} {
+	const maxItems = 9          // Storages per page: three full cards, leaving room for navigation cards within Facebook's limit of 10
 	const maxButtonsPerCard = 3 // Maximum buttons per card
 
+	if breadcrumb == "" {
+		breadcrumb = i18n.T(locale, "storage.select_title")
+	}
+	if startIndex < 0 || startIndex > len(storages) {
+		startIndex = 0
+	}
+
 	// Calculate the end index, making sure not to exceed the slice bounds
 	endIndex := startIndex + maxItems
 	if endIndex > len(storages) {
@@ -122,7 +383,7 @@ func StorageCarouselTemplate(senderID string, storages []string, startIndex int)
 	cardsNeeded := (len(currentStorages) + maxButtonsPerCard - 1) / maxButtonsPerCard
 
 	// Create elements for the carousel
-	elements := make([]map[string]interface{}, 0, cardsNeeded)
+	elements := make([]map[string]interface{}, 0, cardsNeeded+2)
 
 	// Process storages in groups of maxButtonsPerCard
 	for i := 0; i < len(currentStorages); i += maxButtonsPerCard {
@@ -131,28 +392,45 @@ func StorageCarouselTemplate(senderID string, storages []string, startIndex int)
 			end = len(currentStorages)
 		}
 
-		// Get the current group of storages for this card
-		group := currentStorages[i:end]
-
-		// Create buttons for this group
-		buttons := make([]map[string]string, 0, len(group))
-		for j, storage := range group {
+		// Create buttons for this group of storages
+		buttons := make([]map[string]string, 0, end-i)
+		for _, storage := range currentStorages[i:end] {
 			buttons = append(buttons, map[string]string{
 				"type":    "postback",
-				"title":   storage,
-				"payload": fmt.Sprintf("STORAGE_%d", startIndex+i+j+1), // +1 because storage indices start at 1
+				"title":   utils.Truncate(storage.Title, 20),
+				"payload": storage.Payload,
 			})
 		}
 
 		// Create the card
 		element := map[string]interface{}{
-			"title":    "Select Storage",
-			"subtitle": fmt.Sprintf("Page %d/%d", (i/maxButtonsPerCard)+1, cardsNeeded),
+			"title":    utils.Truncate(breadcrumb, 80),
+			"subtitle": i18n.T(locale, "storage.page", "page", (i/maxButtonsPerCard)+1, "pages", cardsNeeded),
 			"buttons":  buttons,
 		}
 		elements = append(elements, element)
 	}
 
+	// Inside a folder, lead with a card to go back up or view the folder's own entries
+	if upPayload != "" || openPayload != "" {
+		buttons := []map[string]string{}
+		if upPayload != "" {
+			buttons = append(buttons, map[string]string{"type": "postback", "title": i18n.T(locale, "button.up"), "payload": upPayload})
+		}
+		if openPayload != "" {
+			buttons = append(buttons, map[string]string{"type": "postback", "title": i18n.T(locale, "button.open_entries"), "payload": openPayload})
+		}
+		subtitle := i18n.T(locale, "folder.empty")
+		if len(storages) > 0 {
+			subtitle = i18n.N(locale, "folder.items", len(storages))
+		}
+		elements = append([]map[string]interface{}{{
+			"title":    utils.Truncate(breadcrumb, 80),
+			"subtitle": subtitle,
+			"buttons":  buttons,
+		}}, elements...)
+	}
+
 	// If there are more items, add a "Next" button to the last element
 	if endIndex < len(storages) {
 		lastElement := elements[len(elements)-1]
@@ -161,19 +439,19 @@ func StorageCarouselTemplate(senderID string, storages []string, startIndex int)
 			// Add Next button to existing card if there's space
 			buttons = append(buttons, map[string]string{
 				"type":    "postback",
-				"title":   "Next Page",
+				"title":   i18n.T(locale, "button.next_page"),
 				"payload": fmt.Sprintf("STORAGE_PAGE_%d", endIndex),
 			})
 			lastElement["buttons"] = buttons
 		} else {
 			// Create a new card for the Next button if no space
 			elements = append(elements, map[string]interface{}{
-				"title":    "More Options",
-				"subtitle": "Continue to next page",
+				"title":    i18n.T(locale, "storage.more_options"),
+				"subtitle": i18n.T(locale, "storage.next_page_hint"),
 				"buttons": []map[string]string{
 					{
 						"type":    "postback",
-						"title":   "Next Page",
+						"title":   i18n.T(locale, "button.next_page"),
 						"payload": fmt.Sprintf("STORAGE_PAGE_%d", endIndex),
 					},
 				},
@@ -185,32 +463,28 @@ func StorageCarouselTemplate(senderID string, storages []string, startIndex int)
 	if startIndex > 0 {
 		firstElement := elements[0]
 		buttons := firstElement["buttons"].([]map[string]string)
+		prevIndex := startIndex - maxItems
+		if prevIndex < 0 {
+			prevIndex = 0
+		}
 		if len(buttons) < maxButtonsPerCard {
 			// Add Previous button to existing card if there's space
-			prevIndex := startIndex - maxItems
-			if prevIndex < 0 {
-				prevIndex = 0
-			}
 			buttons = append(buttons, map[string]string{
 				"type":    "postback",
-				"title":   "Previous Page",
+				"title":   i18n.T(locale, "button.previous_page"),
 				"payload": fmt.Sprintf("STORAGE_PAGE_%d", prevIndex),
 			})
 			firstElement["buttons"] = buttons
 		} else {
 			// Create a new card for the Previous button if no space
-			prevIndex := startIndex - maxItems
-			if prevIndex < 0 {
-				prevIndex = 0
-			}
 			elements = append([]map[string]interface{}{
 				{
-					"title":    "Navigation",
-					"subtitle": "Return to previous page",
+					"title":    i18n.T(locale, "storage.navigation"),
+					"subtitle": i18n.T(locale, "storage.previous_page_hint"),
 					"buttons": []map[string]string{
 						{
 							"type":    "postback",
-							"title":   "Previous Page",
+							"title":   i18n.T(locale, "button.previous_page"),
 							"payload": fmt.Sprintf("STORAGE_PAGE_%d", prevIndex),
 						},
 					},
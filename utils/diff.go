@@ -0,0 +1,50 @@
+package utils
+
+import "strings"
+
+// LineDiff renders a line-by-line diff from a to b. Unchanged lines are
+// indented, removed lines start with "- " and added lines with "+ ".
+func LineDiff(a, b string) string {
+	oldLines := strings.Split(a, "\n")
+	newLines := strings.Split(b, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:].
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			out = append(out, "  "+oldLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+oldLines[i])
+			i++
+		default:
+			out = append(out, "+ "+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		out = append(out, "- "+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		out = append(out, "+ "+newLines[j])
+	}
+	return strings.Join(out, "\n")
+}
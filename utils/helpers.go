@@ -5,31 +5,55 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
-	"time"
+	"strings"
 )
 
-func FormatTimestamp(t time.Time) string {
-	return t.Format("January 2, 2006 @ 3:04pm")
-}
+// MaxMessageLength is the longest text Messenger accepts in a single message.
+const MaxMessageLength = 2000
 
-func Encrypt(plaintext string, key []byte) (string, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
+// Truncate shortens s to at most max characters, marking the cut with an ellipsis.
+func Truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
 	}
+	return string(runes[:max-1]) + "…"
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
+// SplitMessage breaks text into chunks of at most limit characters,
+// preferring to cut at line breaks, then spaces.
+func SplitMessage(text string, limit int) []string {
+	chunks := []string{}
+	for {
+		runes := []rune(text)
+		if len(runes) <= limit {
+			break
+		}
+
+		head := string(runes[:limit])
+		cut := strings.LastIndex(head, "\n")
+		if cut <= 0 {
+			cut = strings.LastIndex(head, " ")
+		}
+		if cut <= 0 {
+			cut = len(head)
+		}
+		chunks = append(chunks, strings.TrimRight(head[:cut], " \n"))
+		text = strings.TrimLeft(text[cut:], " \n")
+	}
+	if text != "" || len(chunks) == 0 {
+		chunks = append(chunks, text)
 	}
+	return chunks
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+func Encrypt(plaintext string, key []byte) (string, error) {
+	ciphertext, err := EncryptBytes([]byte(plaintext), key)
+	if err != nil {
 		return "", err
 	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
 	return base64.URLEncoding.EncodeToString(ciphertext), nil
 }
 
@@ -39,26 +63,50 @@ func Decrypt(ciphertext string, key []byte) (string, error) {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	plaintext, err := DecryptBytes(ciphertextBytes, key)
 	if err != nil {
 		return "", err
 	}
+	return string(plaintext), nil
+}
+
+// EncryptBytes seals plaintext with AES-GCM, prefixing the random nonce.
+func EncryptBytes(plaintext []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(ciphertextBytes) < nonceSize {
-		return "", err
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptBytes opens data sealed by EncryptBytes.
+func DecryptBytes(ciphertext []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
 
-	nonce, ciphertextBytes := ciphertextBytes[:nonceSize], ciphertextBytes[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(plaintext), nil
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return gcm.Open(nil, nonce, ciphertext, nil)
 }
@@ -0,0 +1,14 @@
+package utils
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashPIN hashes the PIN or password that locks a storage.
+func HashPIN(pin string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// PINMatches reports whether pin is the one hash was made from.
+func PINMatches(hash, pin string) bool {
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(pin)) == nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxTagLength is the longest tag name kept; longer hashtags are cut.
+const MaxTagLength = 64
+
+// hashtagPattern matches #tag at the start of the text or after whitespace, so
+// URL fragments such as example.com/#top are not taken for tags.
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+
+// ParseHashtags returns the distinct hashtags in text, lowercased and without
+// the leading "#", in the order they first appear.
+func ParseHashtags(text string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := NormalizeTag(match[1])
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NormalizeTag lowercases a tag name and strips a leading "#".
+func NormalizeTag(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if runes := []rune(name); len(runes) > MaxTagLength {
+		name = string(runes[:MaxTagLength])
+	}
+	return name
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markDoesany/quickymessenger/i18n"
+)
+
+// Date orders a user can choose between.
+const (
+	DateOrderMDY = "mdy" // January 2, 2006
+	DateOrderDMY = "dmy" // 2 January 2006
+	DateOrderYMD = "ymd" // 2006-01-02
+)
+
+// TimeFormat renders times for one user: in their time zone, with a 12 or 24
+// hour clock and their preferred date order.
+type TimeFormat struct {
+	Location  *time.Location
+	Clock24   bool
+	DateOrder string
+	// Language names months, weekdays and relative times; see i18n.
+	Language string
+}
+
+// DefaultTimeFormat is used for users who haven't chosen otherwise.
+var DefaultTimeFormat = TimeFormat{Location: time.Local, DateOrder: DateOrderMDY}
+
+func (f TimeFormat) in(t time.Time) time.Time {
+	if f.Location == nil {
+		return t
+	}
+	return t.In(f.Location)
+}
+
+func (f TimeFormat) dateLayout(weekday bool) string {
+	var layout string
+	switch f.DateOrder {
+	case DateOrderDMY:
+		layout = "2 January 2006"
+	case DateOrderYMD:
+		layout = "2006-01-02"
+	default:
+		layout = "January 2, 2006"
+	}
+	if weekday {
+		if f.DateOrder == DateOrderYMD {
+			return "Monday " + layout
+		}
+		return "Monday, " + layout
+	}
+	return layout
+}
+
+// format renders t with layout, naming months and weekdays in f.Language.
+func (f TimeFormat) format(t time.Time, layout string) string {
+	t = f.in(t)
+	text := t.Format(layout)
+	if f.Language == "" || f.Language == i18n.Fallback {
+		return text
+	}
+	return strings.NewReplacer(
+		t.Month().String(), i18n.T(f.Language, "time.month."+strconv.Itoa(int(t.Month()))),
+		t.Weekday().String(), i18n.T(f.Language, "time.weekday."+strconv.Itoa(int(t.Weekday()))),
+	).Replace(text)
+}
+
+// Timestamp renders a date and time, e.g. "January 2, 2006 @ 3:04pm".
+func (f TimeFormat) Timestamp(t time.Time) string {
+	return f.format(t, f.dateLayout(false)+" @ "+f.timeLayout())
+}
+
+// Day renders a date with its weekday, e.g. "Monday, January 2, 2006".
+func (f TimeFormat) Day(t time.Time) string {
+	return f.format(t, f.dateLayout(true))
+}
+
+// Time renders the time of day, e.g. "3:04pm" or "15:04".
+func (f TimeFormat) Time(t time.Time) string {
+	return f.in(t).Format(f.timeLayout())
+}
+
+func (f TimeFormat) timeLayout() string {
+	if f.Clock24 {
+		return "15:04"
+	}
+	return "3:04pm"
+}
+
+// Relative describes t from now, e.g. "just now", "3 hours ago", "in 2 days"
+// or "yesterday". Anything further than a week away gets its date.
+func (f TimeFormat) Relative(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var amount int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return i18n.T(f.Language, "time.just_now")
+	case d < time.Hour:
+		amount, unit = int(d/time.Minute), "minutes"
+	case d < 24*time.Hour:
+		amount, unit = int(d/time.Hour), "hours"
+	default:
+		days := calendarDays(f.in(t), f.in(now))
+		if days == 1 {
+			if future {
+				return i18n.T(f.Language, "time.tomorrow")
+			}
+			return i18n.T(f.Language, "time.yesterday")
+		}
+		if days > 7 {
+			return f.dateOnly(t)
+		}
+		amount, unit = days, "days"
+	}
+
+	if future {
+		return i18n.N(f.Language, "time.in_"+unit, amount)
+	}
+	return i18n.N(f.Language, "time."+unit+"_ago", amount)
+}
+
+func (f TimeFormat) dateOnly(t time.Time) string {
+	return f.format(t, f.dateLayout(false))
+}
+
+// calendarDays counts the midnights between a and b.
+func calendarDays(a, b time.Time) int {
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(math.Abs(dayB.Sub(dayA).Hours() / 24))
+}
+
+var utcOffsetPattern = regexp.MustCompile(`^(?i:utc|gmt)\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// LoadLocation accepts a time zone name such as "Europe/Berlin" or a fixed
+// offset such as "UTC+5:30".
+func LoadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if m := utcOffsetPattern.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("invalid UTC offset %q", name)
+		}
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(UTCOffsetName(float64(offset)/3600), offset), nil
+	}
+	if strings.EqualFold(name, "utc") || strings.EqualFold(name, "gmt") {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// UTCOffsetName names a fixed offset in hours, e.g. 5.5 as "UTC+05:30".
+func UTCOffsetName(hours float64) string {
+	sign := "+"
+	if hours < 0 {
+		sign, hours = "-", -hours
+	}
+	minutes := int(math.Round(hours * 60))
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, minutes/60, minutes%60)
+}